@@ -0,0 +1,152 @@
+package sqx
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrLockNotAcquired is returned by WithAdvisoryLock when the lock is still held by another session after the timeout.
+var ErrLockNotAcquired = errors.New("advisory lock not acquired")
+
+// Dialect is the SQL dialect of a database, for statements which are not written the same way on every database.
+type Dialect int
+
+const (
+	// MySQL is the dialect of MySQL and compatible databases. It is the default.
+	MySQL Dialect = iota
+	// Postgres is the dialect of PostgreSQL.
+	Postgres
+)
+
+var (
+	dialectMu      sync.RWMutex
+	currentDialect = MySQL
+)
+
+// SetDialect sets the dialect of the database that statements which differ between databases, such as the ones run by
+// WithAdvisoryLock, are written for. It defaults to MySQL, and applies to every database the program uses.
+func SetDialect(d Dialect) {
+	dialectMu.Lock()
+	defer dialectMu.Unlock()
+	currentDialect = d
+}
+
+// dialect returns the dialect set with SetDialect.
+func dialect() Dialect {
+	dialectMu.RLock()
+	defer dialectMu.RUnlock()
+	return currentDialect
+}
+
+// WithAdvisoryLock runs fn while holding the advisory lock called name, e.g. to make sure that a cron job runs on a
+// single instance at a time. It waits up to timeout for the lock, or fails immediately if timeout is zero, and returns
+// ErrLockNotAcquired if another session still holds it.
+//
+// Advisory locks belong to a connection, so WithAdvisoryLock pins one connection from db for the duration of fn, and
+// passes it to fn as a Queryable. The lock is always released when fn returns or panics. If releasing it fails, the
+// connection is discarded, which releases the lock on the server.
+//
+// The lock is taken with GET_LOCK, unless SetDialect was called with Postgres, in which case it is taken with
+// pg_advisory_lock, keyed by hashtext(name).
+func WithAdvisoryLock(
+	ctx context.Context, db *sql.DB, name string, timeout time.Duration, fn func(conn Queryable) error,
+) (err error) {
+	var lock advisoryLock
+	switch d := dialect(); d {
+	case MySQL:
+		lock = mysqlAdvisoryLock
+	case Postgres:
+		lock = postgresAdvisoryLock
+	default:
+		return fmt.Errorf("advisory locks are not supported for dialect %d", d)
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := lock.acquire(ctx, conn, name, timeout); err != nil {
+		return err
+	}
+	defer func() {
+		// Release the lock even if ctx is done, since the connection goes back to the pool.
+		if releaseErr := lock.release(context.Background(), conn, name); releaseErr != nil {
+			_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+			if err == nil {
+				err = releaseErr
+			}
+		}
+	}()
+	return fn(conn)
+}
+
+// advisoryLock holds the statements which take and release an advisory lock on a database.
+type advisoryLock struct {
+	// lock waits for the lock, taking the timeout in seconds as a second argument unless ctxTimeout is set. It returns a
+	// single column, which is 1 if the lock was acquired.
+	lock string
+	// tryLock takes the lock without waiting. It returns a single column, which is 1 if the lock was acquired.
+	tryLock string
+	// unlock releases the lock. It returns a single column, which is 1 if the lock was held.
+	unlock string
+	// ctxTimeout is true if the database cannot time out lock waits by itself, so lock is run with a ctx deadline.
+	ctxTimeout bool
+}
+
+var mysqlAdvisoryLock = advisoryLock{
+	lock:    "SELECT GET_LOCK(?, ?)",
+	tryLock: "SELECT GET_LOCK(?, 0)",
+	unlock:  "SELECT RELEASE_LOCK(?)",
+}
+
+var postgresAdvisoryLock = advisoryLock{
+	lock:       "SELECT 1 FROM pg_advisory_lock(hashtext($1))",
+	tryLock:    "SELECT CASE WHEN pg_try_advisory_lock(hashtext($1)) THEN 1 ELSE 0 END",
+	unlock:     "SELECT CASE WHEN pg_advisory_unlock(hashtext($1)) THEN 1 ELSE 0 END",
+	ctxTimeout: true,
+}
+
+func (l advisoryLock) acquire(ctx context.Context, conn *sql.Conn, name string, timeout time.Duration) error {
+	var acquired sql.NullInt64
+	var err error
+	switch {
+	case timeout <= 0:
+		err = conn.QueryRowContext(ctx, l.tryLock, name).Scan(&acquired)
+	case l.ctxTimeout:
+		lockCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		err = conn.QueryRowContext(lockCtx, l.lock, name).Scan(&acquired)
+		if err != nil && ctx.Err() == nil && lockCtx.Err() != nil {
+			return ErrLockNotAcquired
+		}
+	default:
+		// GET_LOCK takes whole seconds.
+		err = conn.QueryRowContext(ctx, l.lock, name, int64(math.Ceil(timeout.Seconds()))).Scan(&acquired)
+	}
+	if err != nil {
+		return fmt.Errorf("could not acquire advisory lock %q: %w", name, err)
+	}
+	if !acquired.Valid || acquired.Int64 != 1 {
+		return ErrLockNotAcquired
+	}
+	return nil
+}
+
+func (l advisoryLock) release(ctx context.Context, conn *sql.Conn, name string) error {
+	var released sql.NullInt64
+	if err := conn.QueryRowContext(ctx, l.unlock, name).Scan(&released); err != nil {
+		return fmt.Errorf("could not release advisory lock %q: %w", name, err)
+	}
+	if !released.Valid || released.Int64 != 1 {
+		return fmt.Errorf("could not release advisory lock %q: it was not held", name)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package sqx_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stytchauth/sqx"
+	"github.com/stytchauth/sqx/sqxtest"
+)
+
+func TestWithAdvisoryLock(t *testing.T) {
+	ctx := context.Background()
+	db := DB(t)
+
+	t.Run("Runs fn while holding the lock", func(t *testing.T) {
+		ran := false
+		err := sqx.WithAdvisoryLock(ctx, db, "sqx_test_lock", 0, func(conn sqx.Queryable) error {
+			ran = true
+			var one int
+			require.NoError(t, conn.QueryRowContext(ctx, "SELECT 1").Scan(&one))
+
+			err := sqx.WithAdvisoryLock(ctx, db, "sqx_test_lock", 0, func(sqx.Queryable) error {
+				t.Error("the lock should be held")
+				return nil
+			})
+			assert.ErrorIs(t, err, sqx.ErrLockNotAcquired)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.True(t, ran)
+
+		assert.NoError(t, sqx.WithAdvisoryLock(ctx, db, "sqx_test_lock", 0, func(sqx.Queryable) error { return nil }))
+	})
+
+	t.Run("Waits for the lock", func(t *testing.T) {
+		locked := make(chan struct{})
+		done := make(chan error)
+		go func() {
+			done <- sqx.WithAdvisoryLock(ctx, db, "sqx_test_lock", 0, func(sqx.Queryable) error {
+				close(locked)
+				time.Sleep(100 * time.Millisecond)
+				return nil
+			})
+		}()
+		<-locked
+
+		err := sqx.WithAdvisoryLock(ctx, db, "sqx_test_lock", 5*time.Second, func(sqx.Queryable) error { return nil })
+		assert.NoError(t, err)
+		assert.NoError(t, <-done)
+	})
+
+	t.Run("Releases the lock when fn fails or panics", func(t *testing.T) {
+		err := sqx.WithAdvisoryLock(ctx, db, "sqx_test_lock", 0, func(sqx.Queryable) error { return assert.AnError })
+		assert.ErrorIs(t, err, assert.AnError)
+
+		assert.Panics(t, func() {
+			_ = sqx.WithAdvisoryLock(ctx, db, "sqx_test_lock", 0, func(sqx.Queryable) error { panic("boom") })
+		})
+
+		assert.NoError(t, sqx.WithAdvisoryLock(ctx, db, "sqx_test_lock", 0, func(sqx.Queryable) error { return nil }))
+	})
+}
+
+func TestWithAdvisoryLockPostgres(t *testing.T) {
+	ctx := context.Background()
+	sqx.SetDialect(sqx.Postgres)
+	t.Cleanup(func() { sqx.SetDialect(sqx.MySQL) })
+
+	t.Run("Takes and releases the lock with pg_try_advisory_lock", func(t *testing.T) {
+		fake := sqxtest.New()
+		fake.ExpectQuery("SELECT CASE WHEN pg_try_advisory_lock(hashtext($1)) THEN 1 ELSE 0 END").
+			WithArgs("sqx_test_lock").
+			WillReturnRows(sqxtest.NewRows("acquired").AddRow(1))
+		fake.ExpectQuery("SELECT 1").WillReturnRows(sqxtest.NewRows("1").AddRow(1))
+		fake.ExpectQuery("SELECT CASE WHEN pg_advisory_unlock(hashtext($1)) THEN 1 ELSE 0 END").
+			WithArgs("sqx_test_lock").
+			WillReturnRows(sqxtest.NewRows("released").AddRow(1))
+
+		err := sqx.WithAdvisoryLock(ctx, fake.DB(), "sqx_test_lock", 0, func(conn sqx.Queryable) error {
+			var one int
+			return conn.QueryRowContext(ctx, "SELECT 1").Scan(&one)
+		})
+		require.NoError(t, err)
+		fake.AssertExpectations(t)
+	})
+
+	t.Run("Waits for the lock with pg_advisory_lock", func(t *testing.T) {
+		fake := sqxtest.New()
+		fake.ExpectQuery("SELECT 1 FROM pg_advisory_lock(hashtext($1))").
+			WithArgs("sqx_test_lock").
+			WillReturnRows(sqxtest.NewRows("acquired").AddRow(1))
+		fake.ExpectQuery("SELECT CASE WHEN pg_advisory_unlock(hashtext($1)) THEN 1 ELSE 0 END").
+			WithArgs("sqx_test_lock").
+			WillReturnRows(sqxtest.NewRows("released").AddRow(1))
+
+		err := sqx.WithAdvisoryLock(ctx, fake.DB(), "sqx_test_lock", time.Second, func(sqx.Queryable) error {
+			return nil
+		})
+		require.NoError(t, err)
+		fake.AssertExpectations(t)
+	})
+
+	t.Run("Does not run fn if the lock is held", func(t *testing.T) {
+		fake := sqxtest.New()
+		fake.ExpectQuery("SELECT CASE WHEN pg_try_advisory_lock(hashtext($1)) THEN 1 ELSE 0 END").
+			WithArgs("sqx_test_lock").
+			WillReturnRows(sqxtest.NewRows("acquired").AddRow(0))
+
+		err := sqx.WithAdvisoryLock(ctx, fake.DB(), "sqx_test_lock", 0, func(sqx.Queryable) error {
+			t.Error("fn should not run")
+			return nil
+		})
+		assert.ErrorIs(t, err, sqx.ErrLockNotAcquired)
+		fake.AssertExpectations(t)
+	})
+
+	t.Run("Rejects unknown dialects", func(t *testing.T) {
+		sqx.SetDialect(sqx.Dialect(-1))
+		defer sqx.SetDialect(sqx.Postgres)
+		fake := sqxtest.New()
+		err := sqx.WithAdvisoryLock(ctx, fake.DB(), "sqx_test_lock", 0, func(sqx.Queryable) error {
+			t.Error("fn should not run")
+			return nil
+		})
+		assert.Error(t, err)
+		assert.Empty(t, fake.Calls())
+	})
+}
@@ -0,0 +1,59 @@
+package sqx
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// AllInto is like All, but appends the results to *dest rather than allocating a new slice, so that hot paths can reuse
+// a buffer across queries by truncating it with buf[:0] before each call. If an error is returned, *dest is unchanged.
+func (b SelectBuilder[T]) AllInto(dest *[]T) error {
+	if dest == nil {
+		return errors.New("AllInto requires a non-nil dest")
+	}
+	n := len(*dest)
+	results, err := queryRows(b, func(rows *sql.Rows) ([]T, error) {
+		// Appending from the original length means a retried read overwrites a failed attempt's rows.
+		return appendRows((*dest)[:n], rows)
+	})
+	if err != nil {
+		return err
+	}
+	if err := b.loadRelations(results[n:]); err != nil {
+		return err
+	}
+	*dest = results
+	return nil
+}
+
+// AllByKey runs the query and returns the results indexed by key. If key is not unique, later rows overwrite earlier
+// ones - use AllGrouped to keep all of them.
+//
+//	users, err := sqx.AllByKey(sqx.Read[User](ctx).Select("*").From("users"), func(u User) string { return u.ID })
+func AllByKey[K comparable, T any](b SelectBuilder[T], key func(T) K) (map[K]T, error) {
+	rows, err := b.All()
+	if err != nil {
+		return nil, err
+	}
+	dest := make(map[K]T, len(rows))
+	for _, row := range rows {
+		dest[key(row)] = row
+	}
+	return dest, nil
+}
+
+// AllGrouped runs the query and returns the results grouped by key. Each group keeps the order of the query.
+//
+//	pets, err := sqx.AllGrouped(sqx.Read[Pet](ctx).Select("*").From("pets"), func(p Pet) string { return p.OwnerID })
+func AllGrouped[K comparable, T any](b SelectBuilder[T], key func(T) K) (map[K][]T, error) {
+	rows, err := b.All()
+	if err != nil {
+		return nil, err
+	}
+	dest := make(map[K][]T)
+	for _, row := range rows {
+		k := key(row)
+		dest[k] = append(dest[k], row)
+	}
+	return dest, nil
+}
@@ -0,0 +1,66 @@
+package sqx_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stytchauth/sqx"
+	"github.com/stytchauth/sqx/sqxtest"
+)
+
+func TestCollect(t *testing.T) {
+	ctx := context.Background()
+	w1 := Widget{ID: "w1", Status: "great"}
+	w2 := Widget{ID: "w2", Status: "fine"}
+	w3 := Widget{ID: "w3", Status: "great"}
+
+	widgets := func(fake *sqxtest.Fake) sqx.SelectBuilder[Widget] {
+		fake.ExpectQuery("SELECT * FROM widgets").WillReturnRows(sqxtest.RowsFromItems(w1, w2, w3))
+		return sqx.Read[Widget](ctx).WithQueryable(fake).Select("*").From("widgets")
+	}
+
+	t.Run("Indexes rows by key", func(t *testing.T) {
+		fake := sqxtest.New()
+		byID, err := sqx.AllByKey(widgets(fake), func(w Widget) string { return w.ID })
+		require.NoError(t, err)
+		assert.Equal(t, map[string]Widget{"w1": w1, "w2": w2, "w3": w3}, byID)
+
+		byStatus, err := sqx.AllByKey(widgets(fake), func(w Widget) string { return w.Status })
+		require.NoError(t, err)
+		assert.Equal(t, map[string]Widget{"great": w3, "fine": w2}, byStatus, "later rows win")
+	})
+
+	t.Run("Groups rows by key", func(t *testing.T) {
+		fake := sqxtest.New()
+		byStatus, err := sqx.AllGrouped(widgets(fake), func(w Widget) string { return w.Status })
+		require.NoError(t, err)
+		assert.Equal(t, map[string][]Widget{"great": {w1, w3}, "fine": {w2}}, byStatus)
+	})
+
+	t.Run("Returns query errors", func(t *testing.T) {
+		fake := sqxtest.New()
+		fake.ExpectQuery("SELECT * FROM widgets").WillReturnError(assert.AnError)
+		_, err := sqx.AllGrouped(sqx.Read[Widget](ctx).WithQueryable(fake).Select("*").From("widgets"),
+			func(w Widget) string { return w.Status })
+		assert.ErrorIs(t, err, assert.AnError)
+	})
+
+	t.Run("Appends into a caller-provided slice", func(t *testing.T) {
+		fake := sqxtest.New()
+		buf := make([]Widget, 1, 8)
+		buf[0] = Widget{ID: "w0"}
+
+		require.NoError(t, widgets(fake).AllInto(&buf))
+		assert.Equal(t, []Widget{{ID: "w0"}, w1, w2, w3}, buf)
+		assert.Equal(t, 8, cap(buf), "reuses the backing array")
+
+		buf = buf[:0]
+		fake.ExpectQuery("SELECT * FROM widgets").WillReturnError(assert.AnError)
+		err := sqx.Read[Widget](ctx).WithQueryable(fake).Select("*").From("widgets").AllInto(&buf)
+		assert.ErrorIs(t, err, assert.AnError)
+		assert.Empty(t, buf, "dest is unchanged on error")
+	})
+}
@@ -1,8 +1,6 @@
 package sqx
 
-import (
-	scan "github.com/blockloop/scan/v2"
-)
+import "github.com/stytchauth/sqx/internal/dbtag"
 
 // ContainsUpdates returns true if an update filter is nonempty.
 // This function panics if v is not a pointer to a struct.
@@ -10,13 +8,13 @@ func ContainsUpdates(v any, excluded ...string) bool {
 	if isNil(v) {
 		return false
 	}
-	cols, err := scan.ColumnsStrict(v, excluded...)
+	cols, err := dbtag.Columns(v, excluded...)
 	if err != nil {
 		// Err will only be returned if v is not a pointer to a struct
 		// so panics should only ever occur in development (assuming code is ran)
 		panic(err)
 	}
-	vals, err := scan.Values(cols, v)
+	vals, err := dbtag.Values(cols, v)
 	if err != nil {
 		// Err will only be returned if v is not a pointer to a struct
 		// so panics should only ever occur in development (assuming code is ran)
@@ -4,17 +4,26 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"time"
 
 	sq "github.com/stytchauth/squirrel"
 )
 
 // DeleteBuilder wraps squirrel.DeleteBuilder and adds syntactic sugar for common usage patterns.
 type DeleteBuilder struct {
-	builder   sq.DeleteBuilder
-	queryable Queryable
-	ctx       context.Context
-	err       error
-	logger    Logger
+	builder    sq.DeleteBuilder
+	queryable  Queryable
+	ctx        context.Context
+	err        error
+	logger     Logger
+	from       string
+	timeout    time.Duration
+	retry      RetryPolicy
+	idempotent bool
+	// softDelete records the same clauses as builder as an UPDATE, which runs instead if the table has been registered
+	// with RegisterSoftDelete.
+	softDelete sq.UpdateBuilder
+	hard       bool
 }
 
 // ============================================
@@ -23,43 +32,52 @@ type DeleteBuilder struct {
 
 // Prefix adds an expression to the beginning of the query
 func (b DeleteBuilder) Prefix(sql string, args ...interface{}) DeleteBuilder {
+	b.softDelete = b.softDelete.Prefix(sql, args...)
 	return b.withBuilder(b.builder.Prefix(sql, args...))
 }
 
 // PrefixExpr adds an expression to the very beginning of the query
 func (b DeleteBuilder) PrefixExpr(expr Sqlizer) DeleteBuilder {
+	b.softDelete = b.softDelete.PrefixExpr(expr)
 	return b.withBuilder(b.builder.PrefixExpr(expr))
 }
 
 // From sets the table to be deleted from.
 func (b DeleteBuilder) From(from string) DeleteBuilder {
-	return b.withBuilder(b.builder.From(from))
+	b.from = from
+	b.softDelete = b.softDelete.Table(from)
+	return b.withBuilder(b.builder.From(from)).withScope()
 }
 
 // Where adds WHERE expressions to the query.
 //
 // See SelectBuilder.Where for more information.
 func (b DeleteBuilder) Where(pred interface{}, rest ...interface{}) DeleteBuilder {
+	b.softDelete = b.softDelete.Where(pred, rest...)
 	return b.withBuilder(b.builder.Where(pred, rest...))
 }
 
 // OrderBy adds ORDER BY expressions to the query.
 func (b DeleteBuilder) OrderBy(orderBys ...string) DeleteBuilder {
+	b.softDelete = b.softDelete.OrderBy(orderBys...)
 	return b.withBuilder(b.builder.OrderBy(orderBys...))
 }
 
 // Limit sets a LIMIT clause on the query.
 func (b DeleteBuilder) Limit(limit uint64) DeleteBuilder {
+	b.softDelete = b.softDelete.Limit(limit)
 	return b.withBuilder(b.builder.Limit(limit))
 }
 
 // Offset sets a OFFSET clause on the query.
 func (b DeleteBuilder) Offset(offset uint64) DeleteBuilder {
+	b.softDelete = b.softDelete.Offset(offset)
 	return b.withBuilder(b.builder.Offset(offset))
 }
 
 // Suffix adds an expression to the end of the query
 func (b DeleteBuilder) Suffix(sql string, args ...interface{}) DeleteBuilder {
+	b.softDelete = b.softDelete.Suffix(sql, args...)
 	return b.withBuilder(b.builder.Suffix(sql, args...))
 }
 
@@ -67,6 +85,36 @@ func (b DeleteBuilder) Suffix(sql string, args ...interface{}) DeleteBuilder {
 // END: squirrel-UpdateBuilder parity section
 // ==========================================
 
+// Timeout limits how long the delete may run, overriding the default from SetDefaultTimeouts. The delete fails with
+// context.DeadlineExceeded if it runs for longer.
+func (b DeleteBuilder) Timeout(timeout time.Duration) DeleteBuilder {
+	b.timeout = timeout
+	return b
+}
+
+// Hard deletes the matching rows even if the table has been registered with RegisterSoftDelete, e.g. to erase personal
+// data. Without it, DoResult only soft-deletes them.
+func (b DeleteBuilder) Hard() DeleteBuilder {
+	b.hard = true
+	return b
+}
+
+// Idempotent marks the delete as safe to run more than once, so that it is retried according to the RetryPolicy. Only
+// mark writes whose effect is the same if they are applied twice, since a write which failed with a broken connection
+// may have been applied anyway.
+func (b DeleteBuilder) Idempotent() DeleteBuilder {
+	b.idempotent = true
+	return b
+}
+
+// Shard sets the shard key that a ShardedQueryable uses to pick the shard to run the delete against. See WithShardKey.
+func (b DeleteBuilder) Shard(key any) DeleteBuilder {
+	if b.ctx != nil {
+		b.ctx = WithShardKey(b.ctx, key)
+	}
+	return b
+}
+
 // Do executes the DeleteBuilder
 func (b DeleteBuilder) Do() error {
 	_, err := b.DoResult()
@@ -75,32 +123,90 @@ func (b DeleteBuilder) Do() error {
 
 // DoResult executes the DeleteBuilder and also returns the sql.Result for a successful query. This is useful if you
 // wish to check the value of the LastInsertId() or RowsAffected() methods since Do() will discard this information.
+//
+// If the table has been registered with RegisterSoftDelete, the matching rows are soft-deleted using an UPDATE instead,
+// unless Hard is called.
 func (b DeleteBuilder) DoResult() (sql.Result, error) {
 	if b.err != nil {
 		return nil, b.err
 	}
+	if err := checkShardKey(b.ctx, b.from); err != nil {
+		return nil, err
+	}
+	if collector := CollectorFromContext(b.ctx); collector != nil {
+		return collector.record(b)
+	}
 	if b.queryable == nil {
 		return nil, fmt.Errorf("missing queryable - call SetDefaultQueryable or WithQueryable to set it")
 	}
-	return b.builder.RunWith(runShim{b.queryable}).ExecContext(b.ctx)
+	exec := b.builder.RunWith(runShim{b.queryable}).ExecContext
+	if ref, ok := b.softDeleteRef(); ok {
+		exec = softDeleteBuilder(b.softDelete, ref).RunWith(runShim{b.queryable}).ExecContext
+	}
+	return withRetries(b.ctx, b.retry, b.idempotent, b.queryable, b.logger, func() (sql.Result, error) {
+		ctx, cancel := withTimeout(b.ctx, timeoutOr(b.timeout, defaultTimeouts.Delete))
+		defer cancel()
+		return exec(ctx)
+	})
+}
+
+// ToSql returns the SQL query and args that DoResult would run, or the first error that occurred while building it.
+func (b DeleteBuilder) ToSql() (string, []interface{}, error) {
+	if b.err != nil {
+		return "", nil, b.err
+	}
+	if ref, ok := b.softDeleteRef(); ok {
+		return softDeleteBuilder(b.softDelete, ref).ToSql()
+	}
+	return b.builder.ToSql()
 }
 
 // Debug prints the DeleteBuilder state out to the provided logger
 func (b DeleteBuilder) Debug() DeleteBuilder {
-	debug(b.logger, b.builder)
+	debug(b.logger, b)
 	return b
 }
 
 // WithQueryable configures a Queryable for this DeleteBuilder instance
 func (b DeleteBuilder) WithQueryable(queryable Queryable) DeleteBuilder {
-	return DeleteBuilder{builder: b.builder, queryable: queryable, logger: b.logger, ctx: b.ctx, err: b.err}
+	b.queryable = queryable
+	return b
 }
 
 // WithLogger configures a Queryable for this DeleteBuilder instance
 func (b DeleteBuilder) WithLogger(logger Logger) DeleteBuilder {
-	return DeleteBuilder{builder: b.builder, queryable: b.queryable, logger: logger, ctx: b.ctx, err: b.err}
+	b.logger = logger
+	return b
+}
+
+func (b DeleteBuilder) withError(err error) DeleteBuilder {
+	if b.err != nil {
+		return b
+	}
+	b.err = err
+	return b
+}
+
+// softDeleteRef returns the soft delete registration of the table, unless the delete is Hard.
+func (b DeleteBuilder) softDeleteRef() (softDeleteRef, bool) {
+	if b.hard {
+		return softDeleteRef{}, false
+	}
+	return lookupSoftDelete(b.from)
+}
+
+// withScope restricts the DeleteBuilder to the ctx scope if its table was registered with RegisterScopedTable.
+func (b DeleteBuilder) withScope() DeleteBuilder {
+	pred, err := scopePredicate(b.ctx, b.from)
+	if err != nil {
+		return b.withError(err)
+	} else if pred != nil {
+		return b.Where(pred)
+	}
+	return b
 }
 
 func (b DeleteBuilder) withBuilder(builder sq.DeleteBuilder) DeleteBuilder {
-	return DeleteBuilder{builder: builder, queryable: b.queryable, logger: b.logger, ctx: b.ctx, err: b.err}
+	b.builder = builder
+	return b
 }
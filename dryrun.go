@@ -0,0 +1,130 @@
+package sqx
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+type collectorKey struct{}
+
+// Statement is a SQL statement and its args, as captured by a Collector.
+type Statement struct {
+	SQL  string
+	Args []any
+}
+
+// Collector records the statements that write builders would have run in a dry run. See DryRun.
+type Collector struct {
+	mu         sync.Mutex
+	statements []Statement
+}
+
+// DryRun returns a copy of ctx in which write builders (InsertBuilder, InsertManyBuilder, UpdateBuilder and
+// DeleteBuilder) record their SQL and args into a Collector instead of running it. They return an EmptyResult and do
+// not need a Queryable. Reads still run as usual, so that code which reads before writing can be previewed.
+//
+// Use CollectorFromContext to retrieve the recorded statements.
+func DryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, collectorKey{}, &Collector{})
+}
+
+// CollectorFromContext returns the Collector attached to ctx by DryRun, or nil if ctx is not a dry run.
+func CollectorFromContext(ctx context.Context) *Collector {
+	if ctx == nil {
+		return nil
+	}
+	collector, _ := ctx.Value(collectorKey{}).(*Collector)
+	return collector
+}
+
+// record renders builder and appends it to the collected statements.
+func (c *Collector) record(builder Sqlizer) (sql.Result, error) {
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.statements = append(c.statements, Statement{SQL: query, Args: args})
+	return EmptyResult{}, nil
+}
+
+// Statements returns the recorded statements, in the order they were run.
+func (c *Collector) Statements() []Statement {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Statement{}, c.statements...)
+}
+
+// Script returns the recorded statements as a SQL script, with one statement per line and the args inlined as SQL
+// literals. The script is meant for humans to review - it is not safe to run against a database, since values are only
+// escaped on a best-effort basis.
+func (c *Collector) Script() string {
+	var sb strings.Builder
+	for _, statement := range c.Statements() {
+		sb.WriteString(inlineArgs(statement.SQL, statement.Args))
+		sb.WriteString(";\n")
+	}
+	return sb.String()
+}
+
+// String returns the recorded statements as a SQL script. See Script.
+func (c *Collector) String() string {
+	return c.Script()
+}
+
+// inlineArgs replaces each ? placeholder in query which is not inside a quoted string with the corresponding arg.
+func inlineArgs(query string, args []any) string {
+	var sb strings.Builder
+	var quote rune
+	next := 0
+	for _, r := range query {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '?' && next < len(args):
+			sb.WriteString(sqlLiteral(args[next]))
+			next++
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// sqlLiteral renders v as a SQL literal.
+func sqlLiteral(v any) string {
+	value, err := driver.DefaultParameterConverter.ConvertValue(v)
+	if err != nil {
+		return fmt.Sprintf("'%v'", v)
+	}
+	switch value := value.(type) {
+	case nil:
+		return "NULL"
+	case bool:
+		if value {
+			return "TRUE"
+		}
+		return "FALSE"
+	case int64, float64:
+		return fmt.Sprintf("%v", value)
+	case []byte:
+		return "X'" + hex.EncodeToString(value) + "'"
+	case time.Time:
+		return "'" + value.Format("2006-01-02 15:04:05.999999") + "'"
+	case string:
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	default:
+		return fmt.Sprintf("'%v'", value)
+	}
+}
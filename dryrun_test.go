@@ -0,0 +1,60 @@
+package sqx
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type dryRunThingy struct {
+	ID   string `db:"id"`
+	Name string `db:"name"`
+}
+
+func TestDryRun(t *testing.T) {
+	t.Run("Records writes instead of running them", func(t *testing.T) {
+		ctx := DryRun(context.Background())
+
+		require.NoError(t, Write(ctx).Insert("things").SetMap(ToSetMap(&dryRunThingy{ID: "thing-1", Name: "O'Brien"})).Do())
+		require.NoError(t, TypedWrite[dryRunThingy](ctx).InsertMany("things").FromItems([]dryRunThingy{{ID: "thing-2"}}).Do())
+		require.NoError(t, Write(ctx).Update("things").Set("name", nil).Where(Eq{"id": "thing-1"}).Do())
+		res, err := Write(ctx).Delete("things").Where(Eq{"id": "thing-2"}).DoResult()
+		require.NoError(t, err)
+		assert.Equal(t, EmptyResult{}, res)
+
+		collector := CollectorFromContext(ctx)
+		require.NotNil(t, collector)
+		assert.Equal(t, []Statement{
+			{SQL: "INSERT INTO things (id,name) VALUES (?,?)", Args: []any{"thing-1", "O'Brien"}},
+			{SQL: "INSERT INTO things (id,name) VALUES (?,?)", Args: []any{"thing-2", ""}},
+			{SQL: "UPDATE things SET name = ? WHERE id = ?", Args: []any{nil, "thing-1"}},
+			{SQL: "DELETE things FROM things WHERE id = ?", Args: []any{"thing-2"}},
+		}, collector.Statements())
+		assert.Equal(t, "INSERT INTO things (id,name) VALUES ('thing-1','O''Brien');\n"+
+			"INSERT INTO things (id,name) VALUES ('thing-2','');\n"+
+			"UPDATE things SET name = NULL WHERE id = 'thing-1';\n"+
+			"DELETE things FROM things WHERE id = 'thing-2';\n", collector.Script())
+	})
+
+	t.Run("Returns builder errors", func(t *testing.T) {
+		ctx := DryRun(context.Background())
+		err := Write(ctx).Insert("things").SetMap(nil, assert.AnError).Do()
+		assert.ErrorIs(t, err, assert.AnError)
+		assert.Empty(t, CollectorFromContext(ctx).Statements())
+	})
+
+	t.Run("Contexts are not dry runs by default", func(t *testing.T) {
+		assert.Nil(t, CollectorFromContext(context.Background()))
+	})
+}
+
+func TestInlineArgs(t *testing.T) {
+	ts := time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+	assert.Equal(t,
+		"SELECT '?' FROM t WHERE a = 1 AND b = TRUE AND c = '2022-03-04 05:06:07' AND d = X'0102' AND e = 'x' AND f = NULL",
+		inlineArgs("SELECT '?' FROM t WHERE a = ? AND b = ? AND c = ? AND d = ? AND e = ? AND f = ?",
+			[]any{1, true, ts, []byte{1, 2}, Ptr("x"), (*string)(nil)}))
+}
@@ -1,7 +1,8 @@
 package sqx
 
 // EmptyResult represents a result with no rows affected.
-// This is used for an UpdateBuilder that has no pending changes since the query would be a noop.
+// This is used for an UpdateBuilder that has no pending changes since the query would be a noop, and for writes made
+// in a DryRun.
 type EmptyResult struct{}
 
 func (e EmptyResult) LastInsertId() (int64, error) {
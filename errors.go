@@ -1,6 +1,14 @@
 package sqx
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrStaleObject is returned by an UpdateBuilder configured with WithOptimisticLock when no rows were affected by the
+// update. This means that the row's version column no longer matches the expected version - i.e. someone else has
+// modified the row since it was read - or that the row no longer exists.
+var ErrStaleObject = errors.New("stale object: no rows matched the expected version")
 
 // ErrTooManyRows indicates that a query returned more rows than expected. This is used in calls to OneStrict() which
 // expects a single row to be returned. In Strict mode, this error is raised if the number of rows returned is not equal
@@ -0,0 +1,140 @@
+package sqx
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/stytchauth/sqx/internal/dbtag"
+)
+
+// scanRows scans every row in rows into a slice of T, closing rows when done. If T is a struct, each column is scanned
+// into the field whose db tag matches the column name and unknown columns are discarded. Tuples are scanned by column
+// position. Otherwise, including for scannable structs such as time.Time, the result set must contain exactly one
+// column.
+func scanRows[T any](rows *sql.Rows) ([]T, error) {
+	return appendRows[T](nil, rows)
+}
+
+// appendRows is like scanRows, but appends the rows to dest and returns the extended slice.
+func appendRows[T any](dest []T, rows *sql.Rows) ([]T, error) {
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	itemType := reflect.TypeOf((*T)(nil)).Elem()
+	if itemType == mapType {
+		return scanMaps(dest, rows, cols)
+	}
+	isPrimitive := isScalarType(itemType)
+	if isPrimitive {
+		if err := checkScalarColumns(itemType, cols); err != nil {
+			return nil, err
+		}
+	}
+	isTuple := reflect.PtrTo(itemType).Implements(tupleType)
+	if isTuple {
+		var probe T
+		if _, err := tuplePointers(&probe, cols); err != nil {
+			return nil, err
+		}
+	}
+
+	var fields map[string]dbtag.Field
+	if !isPrimitive && !isTuple {
+		fields = dbtag.ScanFields(itemType)
+	}
+
+	for rows.Next() {
+		var item T
+		var pointers []any
+		if isPrimitive {
+			pointers = []any{&item}
+		} else if isTuple {
+			pointers, _ = tuplePointers(&item, cols)
+		} else {
+			pointers = structPointers(reflect.ValueOf(&item).Elem(), cols, fields)
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		dest = append(dest, item)
+	}
+	return dest, rows.Err()
+}
+
+// SelectColumns returns the column list that scans into every db-tagged field of the struct T, for use with Select.
+// Columns of nested struct fields with a db tag are qualified with the tag as a table alias, and aliased so that they
+// scan back into the nested struct:
+//
+//	type UserWithPet struct {
+//		User `db:"u"`
+//		Pet  `db:"p"`
+//	}
+//
+//	sqx.SelectColumns[UserWithPet]() // "u.id AS u__id", "u.name AS u__name", "p.id AS p__id", ...
+//
+// Other columns are returned as they are.
+func SelectColumns[T any]() []string {
+	columns, _ := structColumns[T]("")
+	return columns
+}
+
+// structColumns returns the Select column list for the struct T, qualifying unprefixed columns with alias if it is set.
+// Columns listed in excluded are omitted - nested columns are excluded by their prefixed name, e.g. "p.id".
+func structColumns[T any](alias string, excluded ...string) ([]string, error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot select the columns of %s: not a struct", t)
+	}
+	var columns []string
+	for _, f := range dbtag.PrefixedFields(t) {
+		name := strings.Join(append(append([]string{}, f.Prefix...), f.Column), ".")
+		if !contains(excluded, name) {
+			columns = append(columns, selectColumn(f, alias))
+		}
+	}
+	return columns, nil
+}
+
+// selectColumn renders the Select column for f. Columns without a prefix are qualified with alias, if it is set.
+func selectColumn(f dbtag.PrefixedField, alias string) string {
+	if len(f.Prefix) == 0 {
+		if alias == "" {
+			return f.Column
+		}
+		return alias + "." + f.Column
+	}
+	table := f.Prefix[len(f.Prefix)-1]
+	return table + "." + f.Column + " AS " + strings.Join(f.Prefix, dbtag.PrefixSeparator) + dbtag.PrefixSeparator + f.Column
+}
+
+// structPointers returns one scan destination per column, pointing into the matching field of item. Columns with no
+// matching field are scanned into a throwaway value.
+func structPointers(item reflect.Value, cols []string, fields map[string]dbtag.Field) []any {
+	pointers := make([]any, len(cols))
+	for i, col := range cols {
+		f, ok := fields[col]
+		if !ok {
+			var discard any
+			pointers[i] = &discard
+			continue
+		}
+		pointers[i] = item.FieldByIndex(f.Index).Addr().Interface()
+	}
+	return pointers
+}
+
+// contains reports whether list contains s.
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package sqx_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stytchauth/sqx"
+	"github.com/stytchauth/sqx/sqxtest"
+)
+
+type joinedWidget struct {
+	Widget `db:"w"`
+	Owner  widgetOwner `db:"o"`
+	Count  int         `db:"count"`
+}
+
+type widgetOwner struct {
+	ID   string `db:"owner_id"`
+	Name string `db:"name"`
+}
+
+func TestSelectColumns(t *testing.T) {
+	assert.Equal(t, []string{
+		"w.widget_id AS w__widget_id",
+		"w.status AS w__status",
+		"w.enabled AS w__enabled",
+		"w.owner_id AS w__owner_id",
+		"o.owner_id AS o__owner_id",
+		"o.name AS o__name",
+		"count",
+	}, sqx.SelectColumns[joinedWidget]())
+	assert.Equal(t, []string{"widget_id", "status", "enabled", "owner_id"}, sqx.SelectColumns[Widget]())
+	assert.Nil(t, sqx.SelectColumns[string]())
+}
+
+func TestScanPrefixedStructs(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Scans dotted and aliased columns into nested structs", func(t *testing.T) {
+		fake := sqxtest.New()
+		fake.ExpectQuery("SELECT * FROM widgets w JOIN owners o ON o.owner_id = w.owner_id").
+			WillReturnRows(sqxtest.NewRows("w__widget_id", "w__status", "o.owner_id", "o.name", "count").
+				AddRow("w1", "great", "o1", "alice", 3))
+
+		widgets, err := sqx.Read[joinedWidget](ctx).
+			WithQueryable(fake).
+			Select("*").
+			From("widgets w").
+			Join("owners o ON o.owner_id = w.owner_id").
+			All()
+		require.NoError(t, err)
+		assert.Equal(t, []joinedWidget{{
+			Widget: Widget{ID: "w1", Status: "great"},
+			Owner:  widgetOwner{ID: "o1", Name: "alice"},
+			Count:  3,
+		}}, widgets)
+	})
+
+	t.Run("Scans a join from the database", func(t *testing.T) {
+		tx := Tx(t)
+		setupTestWidgetsTable(t, tx)
+		_, err := tx.Exec(`DROP TABLE IF EXISTS sqx_owners_test;`)
+		require.NoError(t, err)
+		_, err = tx.Exec(`CREATE TABLE sqx_owners_test (owner_id VARCHAR(128) NOT NULL, name VARCHAR(128) NOT NULL)`)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_, err := tx.Exec(`DROP TABLE IF EXISTS sqx_owners_test;`)
+			require.NoError(t, err)
+		})
+
+		widget := Widget{ID: "w1", Status: "great", Enabled: true, OwnerID: sqx.Ptr("o1")}
+		dbWidget := newDBWidget()
+		require.NoError(t, dbWidget.Create(ctx, tx, &widget))
+		_, err = tx.Exec(`INSERT INTO sqx_owners_test (owner_id, name) VALUES ('o1', 'alice')`)
+		require.NoError(t, err)
+
+		widgets, err := sqx.Read[joinedWidget](ctx).
+			WithQueryable(tx).
+			Select(sqx.SelectColumns[joinedWidget]()[:6]...).
+			Column("1 AS count").
+			From("sqx_widgets_test w").
+			Join("sqx_owners_test o ON o.owner_id = w.owner_id").
+			All()
+		require.NoError(t, err)
+		assert.Equal(t, []joinedWidget{{Widget: widget, Owner: widgetOwner{ID: "o1", Name: "alice"}, Count: 1}}, widgets)
+	})
+}
+
+func TestSelectStruct(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Selects the struct's columns", func(t *testing.T) {
+		query, _, err := sqx.Read[Widget](ctx).SelectStruct().From("widgets").ToSql()
+		require.NoError(t, err)
+		assert.Equal(t, "SELECT widget_id, status, enabled, owner_id FROM widgets", query)
+
+		query, _, err = sqx.Read[Widget](ctx).SelectStruct("owner_id", "enabled").From("widgets").ToSql()
+		require.NoError(t, err)
+		assert.Equal(t, "SELECT widget_id, status FROM widgets", query)
+
+		query, _, err = sqx.Read[joinedWidget](ctx).SelectStructAlias("x", "w.owner_id", "count").From("widgets w").ToSql()
+		require.NoError(t, err)
+		assert.Equal(t, "SELECT w.widget_id AS w__widget_id, w.status AS w__status, w.enabled AS w__enabled, "+
+			"o.owner_id AS o__owner_id, o.name AS o__name FROM widgets w", query)
+
+		query, _, err = sqx.Read[Widget](ctx).SelectStructAlias("w", "owner_id").From("widgets w").ToSql()
+		require.NoError(t, err)
+		assert.Equal(t, "SELECT w.widget_id, w.status, w.enabled FROM widgets w", query)
+	})
+
+	t.Run("Requires a struct", func(t *testing.T) {
+		_, _, err := sqx.Read[string](ctx).SelectStruct().From("widgets").ToSql()
+		assert.ErrorContains(t, err, "not a struct")
+	})
+
+	t.Run("Ignores columns added to the table", func(t *testing.T) {
+		tx := Tx(t)
+		setupTestWidgetsTable(t, tx)
+		dbWidget := newDBWidget()
+		widget := newWidget("great")
+		require.NoError(t, dbWidget.Create(ctx, tx, &widget))
+		_, err := tx.Exec(`ALTER TABLE sqx_widgets_test ADD COLUMN color VARCHAR(16) NOT NULL DEFAULT 'red'`)
+		require.NoError(t, err)
+
+		widgets, err := sqx.Read[Widget](ctx).WithQueryable(tx).SelectStruct().From("sqx_widgets_test").All()
+		require.NoError(t, err)
+		assert.Equal(t, []Widget{widget}, widgets)
+	})
+}
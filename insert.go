@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"time"
 
 	sq "github.com/stytchauth/squirrel"
 )
@@ -15,6 +16,12 @@ type InsertBuilder struct {
 	ctx       context.Context
 	err       error
 	logger    Logger
+	table     string
+	// rows records the inserted columns and values, so that the ctx scope can be checked, see RegisterScopedTable
+	rows       insertedRows
+	timeout    time.Duration
+	retry      RetryPolicy
+	idempotent bool
 }
 
 // ============================================
@@ -38,11 +45,13 @@ func (b InsertBuilder) Options(options ...string) InsertBuilder {
 
 // Columns adds insert columns to the query.
 func (b InsertBuilder) Columns(columns ...string) InsertBuilder {
+	b.rows = b.rows.withColumns(columns...)
 	return b.withBuilder(b.builder.Columns(columns...))
 }
 
 // Values adds a single row's values to the query.
 func (b InsertBuilder) Values(values ...any) InsertBuilder {
+	b.rows = b.rows.withValues(values...)
 	return b.withBuilder(b.builder.Values(values...))
 }
 
@@ -58,12 +67,18 @@ func (b InsertBuilder) SuffixExpr(expr Sqlizer) InsertBuilder {
 
 // SetMap set columns and values for insert builder from a map of column name and value
 // note that it will reset all previous columns and values was set if any
+// Timestamp columns managed by ToSetMap are set to the current time
 func (b InsertBuilder) SetMap(clauses map[string]interface{}, errors ...error) InsertBuilder {
 	for _, err := range errors {
 		if err != nil {
 			return b.withError(err)
 		}
 	}
+	clauses, err := scopeSetMap(b.ctx, b.table, fillAutoTimestamps(clauses, clock()))
+	if err != nil {
+		return b.withError(err)
+	}
+	b.rows = withSetMap(clauses)
 	return b.withBuilder(b.builder.SetMap(clauses))
 }
 
@@ -71,6 +86,29 @@ func (b InsertBuilder) SetMap(clauses map[string]interface{}, errors ...error) I
 // END: squirrel-InsertBuilder parity section
 // ==========================================
 
+// Timeout limits how long the insert may run, overriding the default from SetDefaultTimeouts. The insert fails with
+// context.DeadlineExceeded if it runs for longer.
+func (b InsertBuilder) Timeout(timeout time.Duration) InsertBuilder {
+	b.timeout = timeout
+	return b
+}
+
+// Idempotent marks the insert as safe to run more than once, so that it is retried according to the RetryPolicy. Only
+// mark writes whose effect is the same if they are applied twice, since a write which failed with a broken connection
+// may have been applied anyway.
+func (b InsertBuilder) Idempotent() InsertBuilder {
+	b.idempotent = true
+	return b
+}
+
+// Shard sets the shard key that a ShardedQueryable uses to pick the shard to run the insert against. See WithShardKey.
+func (b InsertBuilder) Shard(key any) InsertBuilder {
+	if b.ctx != nil {
+		b.ctx = WithShardKey(b.ctx, key)
+	}
+	return b
+}
+
 // Do executes the InsertBuilder
 func (b InsertBuilder) Do() error {
 	_, err := b.DoResult()
@@ -83,35 +121,60 @@ func (b InsertBuilder) DoResult() (sql.Result, error) {
 	if b.err != nil {
 		return nil, b.err
 	}
+	if err := checkShardKey(b.ctx, b.table); err != nil {
+		return nil, err
+	}
+	if err := b.rows.checkScope(b.ctx, b.table); err != nil {
+		return nil, err
+	}
+	if collector := CollectorFromContext(b.ctx); collector != nil {
+		return collector.record(b)
+	}
 	if b.queryable == nil {
 		return nil, fmt.Errorf("missing queryable - call SetDefaultQueryable or WithQueryable to set it")
 	}
-	return b.builder.RunWith(runShim{b.queryable}).ExecContext(b.ctx)
+	return withRetries(b.ctx, b.retry, b.idempotent, b.queryable, b.logger, func() (sql.Result, error) {
+		ctx, cancel := withTimeout(b.ctx, timeoutOr(b.timeout, defaultTimeouts.Insert))
+		defer cancel()
+		return b.builder.RunWith(runShim{b.queryable}).ExecContext(ctx)
+	})
+}
+
+// ToSql returns the SQL query and args that DoResult would run, or the first error that occurred while building it.
+func (b InsertBuilder) ToSql() (string, []interface{}, error) {
+	if b.err != nil {
+		return "", nil, b.err
+	}
+	return b.builder.ToSql()
 }
 
 // Debug prints the InsertBuilder state out to the provided logger
 func (b InsertBuilder) Debug() InsertBuilder {
-	debug(b.logger, b.builder)
+	debug(b.logger, b)
 	return b
 }
 
 // WithQueryable configures a Queryable for this InsertBuilder instance
 func (b InsertBuilder) WithQueryable(queryable Queryable) InsertBuilder {
-	return InsertBuilder{builder: b.builder, queryable: queryable, logger: b.logger, ctx: b.ctx, err: b.err}
+	b.queryable = queryable
+	return b
 }
 
 // WithLogger configures a Queryable for this InsertBuilder instance
 func (b InsertBuilder) WithLogger(logger Logger) InsertBuilder {
-	return InsertBuilder{builder: b.builder, queryable: b.queryable, logger: logger, ctx: b.ctx, err: b.err}
+	b.logger = logger
+	return b
 }
 
 func (b InsertBuilder) withError(err error) InsertBuilder {
 	if b.err != nil {
 		return b
 	}
-	return InsertBuilder{builder: b.builder, queryable: b.queryable, logger: b.logger, ctx: b.ctx, err: err}
+	b.err = err
+	return b
 }
 
 func (b InsertBuilder) withBuilder(builder sq.InsertBuilder) InsertBuilder {
-	return InsertBuilder{builder: builder, queryable: b.queryable, logger: b.logger, ctx: b.ctx, err: b.err}
+	b.builder = builder
+	return b
 }
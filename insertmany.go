@@ -4,9 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"time"
 
-	"github.com/blockloop/scan/v2"
 	sq "github.com/stytchauth/squirrel"
+
+	"github.com/stytchauth/sqx/internal/dbtag"
 )
 
 // InsertManyBuilder wraps squirrel.InsertBuilder and adds syntactic sugar for common usage patterns.
@@ -20,6 +22,12 @@ type InsertManyBuilder[T any] struct {
 	ctx       context.Context
 	err       error
 	logger    Logger
+	table     string
+	// rows records the inserted columns and values, so that the ctx scope can be checked, see RegisterScopedTable
+	rows       insertedRows
+	timeout    time.Duration
+	retry      RetryPolicy
+	idempotent bool
 }
 
 // ============================================
@@ -28,11 +36,13 @@ type InsertManyBuilder[T any] struct {
 
 // Columns adds insert columns to the query.
 func (b InsertManyBuilder[T]) Columns(columns ...string) InsertManyBuilder[T] {
+	b.rows = b.rows.withColumns(columns...)
 	return b.withBuilder(b.builder.Columns(columns...))
 }
 
 // Values adds a single row's values to the query.
 func (b InsertManyBuilder[T]) Values(values ...any) InsertManyBuilder[T] {
+	b.rows = b.rows.withValues(values...)
 	return b.withBuilder(b.builder.Values(values...))
 }
 
@@ -40,25 +50,77 @@ func (b InsertManyBuilder[T]) Values(values ...any) InsertManyBuilder[T] {
 // END: squirrel-InsertBuilder parity section
 // ==========================================
 
+// Timeout limits how long the insert may run, overriding the default from SetDefaultTimeouts. The insert fails with
+// context.DeadlineExceeded if it runs for longer.
+func (b InsertManyBuilder[T]) Timeout(timeout time.Duration) InsertManyBuilder[T] {
+	b.timeout = timeout
+	return b
+}
+
+// Idempotent marks the insert as safe to run more than once, so that it is retried according to the RetryPolicy. Only
+// mark writes whose effect is the same if they are applied twice, since a write which failed with a broken connection
+// may have been applied anyway.
+func (b InsertManyBuilder[T]) Idempotent() InsertManyBuilder[T] {
+	b.idempotent = true
+	return b
+}
+
+// Shard sets the shard key that a ShardedQueryable uses to pick the shard to run the insert against. See WithShardKey.
+func (b InsertManyBuilder[T]) Shard(key any) InsertManyBuilder[T] {
+	if b.ctx != nil {
+		b.ctx = WithShardKey(b.ctx, key)
+	}
+	return b
+}
+
 // FromItems generates an InsertManyBuilder from a slice of items. The first item in the slice is used to determine the
 // columns for the insert statement. If excluded columns are provided, they will be removed from the list of columns.
-// All items should be of the same type.
+// All items should be of the same type. Columns tagged with the "version" option are always skipped, so they are left
+// to the column default, like with ToSetMap. Columns tagged with the "autoupdate" option, and columns tagged with the
+// "autocreate" option that are zero, are set to the current time - see SetClock.
 func (b InsertManyBuilder[T]) FromItems(items []T, excluded ...string) InsertManyBuilder[T] {
 	if len(items) == 0 {
 		return b
 	}
 
-	cols, err := scan.ColumnsStrict(&items[0], excluded...)
+	excluded = append(dbtag.ColumnsWithOption(&items[0], dbtag.OptVersion), excluded...)
+	cols, err := dbtag.Columns(&items[0], excluded...)
 	if err != nil {
 		return b.withError(err)
 	}
 
-	b = b.Columns(cols...)
+	autoCreate := dbtag.ColumnsWithOption(&items[0], dbtag.OptAutoCreate)
+	autoUpdate := dbtag.ColumnsWithOption(&items[0], dbtag.OptAutoUpdate)
+	now := clock()
+
+	scope, err := scopeValues(b.ctx, b.table, false)
+	if err != nil {
+		return b.withError(err)
+	}
+	var scopeCols []string
+	for _, column := range sortedKeys(scope) {
+		if !contains(cols, column) {
+			scopeCols = append(scopeCols, column)
+		}
+	}
+
+	b = b.Columns(append(append([]string{}, cols...), scopeCols...)...)
 	for _, item := range items {
-		vals, err := scan.Values(cols, &item)
+		vals, err := dbtag.Values(cols, &item)
 		if err != nil {
 			return b.withError(err)
 		}
+		for i, col := range cols {
+			if contains(autoUpdate, col) || (contains(autoCreate, col) && isZero(vals[i])) {
+				vals[i] = now
+			}
+			if err := checkScopeValue(scope, col, vals[i]); err != nil {
+				return b.withError(err)
+			}
+		}
+		for _, col := range scopeCols {
+			vals = append(vals, scope[col])
+		}
 		b = b.Values(vals...)
 	}
 	return b
@@ -76,15 +138,36 @@ func (b InsertManyBuilder[T]) DoResult() (sql.Result, error) {
 	if b.err != nil {
 		return nil, b.err
 	}
+	if err := checkShardKey(b.ctx, b.table); err != nil {
+		return nil, err
+	}
+	if err := b.rows.checkScope(b.ctx, b.table); err != nil {
+		return nil, err
+	}
+	if collector := CollectorFromContext(b.ctx); collector != nil {
+		return collector.record(b)
+	}
 	if b.queryable == nil {
 		return nil, fmt.Errorf("missing queryable - call SetDefaultQueryable or WithQueryable to set it")
 	}
-	return b.builder.RunWith(runShim{b.queryable}).ExecContext(b.ctx)
+	return withRetries(b.ctx, b.retry, b.idempotent, b.queryable, b.logger, func() (sql.Result, error) {
+		ctx, cancel := withTimeout(b.ctx, timeoutOr(b.timeout, defaultTimeouts.Insert))
+		defer cancel()
+		return b.builder.RunWith(runShim{b.queryable}).ExecContext(ctx)
+	})
+}
+
+// ToSql returns the SQL query and args that DoResult would run, or the first error that occurred while building it.
+func (b InsertManyBuilder[T]) ToSql() (string, []interface{}, error) {
+	if b.err != nil {
+		return "", nil, b.err
+	}
+	return b.builder.ToSql()
 }
 
 // Debug prints the InsertManyBuilder state out to the provided logger
 func (b InsertManyBuilder[T]) Debug() InsertManyBuilder[T] {
-	debug(b.logger, b.builder)
+	debug(b.logger, b)
 	return b
 }
 
@@ -95,21 +178,25 @@ func (b InsertManyBuilder[T]) SuffixExpr(expr Sqlizer) InsertManyBuilder[T] {
 
 // WithQueryable configures a Queryable for this InsertManyBuilder instance
 func (b InsertManyBuilder[T]) WithQueryable(queryable Queryable) InsertManyBuilder[T] {
-	return InsertManyBuilder[T]{builder: b.builder, queryable: queryable, logger: b.logger, ctx: b.ctx, err: b.err}
+	b.queryable = queryable
+	return b
 }
 
 // WithLogger configures a Queryable for this InsertManyBuilder instance
 func (b InsertManyBuilder[T]) WithLogger(logger Logger) InsertManyBuilder[T] {
-	return InsertManyBuilder[T]{builder: b.builder, queryable: b.queryable, logger: logger, ctx: b.ctx, err: b.err}
+	b.logger = logger
+	return b
 }
 
 func (b InsertManyBuilder[T]) withError(err error) InsertManyBuilder[T] {
 	if b.err != nil {
 		return b
 	}
-	return InsertManyBuilder[T]{builder: b.builder, queryable: b.queryable, logger: b.logger, ctx: b.ctx, err: err}
+	b.err = err
+	return b
 }
 
 func (b InsertManyBuilder[T]) withBuilder(builder sq.InsertBuilder) InsertManyBuilder[T] {
-	return InsertManyBuilder[T]{builder: builder, queryable: b.queryable, logger: b.logger, ctx: b.ctx, err: b.err}
+	b.builder = builder
+	return b
 }
@@ -0,0 +1,344 @@
+// Package dbtag reflects over the "db" struct tags used by sqx to map struct fields to columns.
+package dbtag
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/blockloop/scan/v2"
+)
+
+// Name is the struct tag used to map struct fields to columns.
+const Name = "db"
+
+// RelationName is the struct tag used to mark the fields that related rows are loaded into, e.g. `sqx:"rel=pets"`.
+// Fields tagged with it are never mapped to columns.
+const RelationName = "sqx"
+
+// Options that may follow the column name in a db struct tag, e.g. `db:"version,version"`.
+const (
+	// OptVersion marks the column used for optimistic concurrency control. See sqx.UpdateBuilder.WithOptimisticLock.
+	OptVersion = "version"
+	// OptAutoCreate marks a timestamp column that is set to the current time when the row is inserted.
+	OptAutoCreate = "autocreate"
+	// OptAutoUpdate marks a timestamp column that is set to the current time whenever the row is inserted or updated.
+	OptAutoUpdate = "autoupdate"
+)
+
+// Options holds the comma-separated options that follow the column name in a db struct tag.
+type Options []string
+
+// Has reports whether opt is present in the tag options.
+func (o Options) Has(opt string) bool {
+	for _, option := range o {
+		if option == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse splits a db struct tag into its column name and options.
+func Parse(tag string) (string, Options) {
+	parts := strings.Split(tag, ",")
+	var opts Options
+	for _, opt := range parts[1:] {
+		if opt = strings.TrimSpace(opt); opt != "" {
+			opts = append(opts, opt)
+		}
+	}
+	return strings.TrimSpace(parts[0]), opts
+}
+
+// Field describes a single db-tagged struct field.
+type Field struct {
+	Column string
+	Index  []int
+	Opts   Options
+}
+
+var (
+	columnFieldsCache   sync.Map
+	scanFieldsCache     sync.Map
+	prefixedFieldsCache sync.Map
+)
+
+// Fields returns the db-tagged fields of the struct type t that can be written to the database, in declaration
+// order. Fields without a db tag or tagged with "-" are skipped, and untagged nested structs are flattened. This
+// matches the behavior of scan.ColumnsStrict, except that any tag options are stripped from the column name.
+func Fields(t reflect.Type) []Field {
+	if cached, ok := columnFieldsCache.Load(t); ok {
+		return cached.([]Field)
+	}
+	fields := appendColumnFields(nil, t, nil)
+	columnFieldsCache.Store(t, fields)
+	return fields
+}
+
+func appendColumnFields(fields []Field, t reflect.Type, index []int) []Field {
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		if !structField.IsExported() {
+			continue
+		}
+		if _, ok := relationTag(structField); ok {
+			continue
+		}
+		fieldIndex := append(append([]int{}, index...), i)
+
+		if structField.Type.Kind() == reflect.Struct && !isValidSQLValueType(structField.Type) {
+			fields = appendColumnFields(fields, structField.Type, fieldIndex)
+			continue
+		}
+
+		tag, ok := structField.Tag.Lookup(Name)
+		if !ok || tag == "-" {
+			continue
+		}
+		column, opts := Parse(tag)
+		if column == "" {
+			continue
+		}
+		if isSupportedColumnType(structField.Type) || isValidSQLValueType(structField.Type) {
+			fields = append(fields, Field{Column: column, Index: fieldIndex, Opts: opts})
+		}
+	}
+	return fields
+}
+
+// ScanFields returns a map of column name to field for every db-tagged field of the struct type t that a result column
+// may be scanned into. Nested structs are searched as well, which matches the behavior of scan.RowsStrict.
+//
+// The columns of a nested struct field with a db tag, e.g. `db:"p"`, are prefixed with the tag: a field tagged
+// `db:"id"` in it is scanned from either the column "p.id" or "p__id".
+func ScanFields(t reflect.Type) map[string]Field {
+	if cached, ok := scanFieldsCache.Load(t); ok {
+		return cached.(map[string]Field)
+	}
+	fields := make(map[string]Field)
+	walkScanFields(t, nil, nil, func(prefix []string, f Field) {
+		if len(prefix) == 0 {
+			fields[f.Column] = f
+			return
+		}
+		fields[strings.Join(prefix, ".")+"."+f.Column] = f
+		fields[strings.Join(prefix, PrefixSeparator)+PrefixSeparator+f.Column] = f
+	})
+	scanFieldsCache.Store(t, fields)
+	return fields
+}
+
+// PrefixSeparator separates the prefix of a nested struct field from its columns in column aliases, e.g. "p__id".
+const PrefixSeparator = "__"
+
+// PrefixedField is a db-tagged field that may be nested in struct fields with a db tag, which are its prefix.
+type PrefixedField struct {
+	Field
+	Prefix []string
+}
+
+// PrefixedFields returns every db-tagged field of the struct type t that a result column may be scanned into, with the
+// prefixes of the nested struct fields they are in, in declaration order.
+func PrefixedFields(t reflect.Type) []PrefixedField {
+	if cached, ok := prefixedFieldsCache.Load(t); ok {
+		return cached.([]PrefixedField)
+	}
+	var fields []PrefixedField
+	walkScanFields(t, nil, nil, func(prefix []string, f Field) {
+		fields = append(fields, PrefixedField{Field: f, Prefix: prefix})
+	})
+	prefixedFieldsCache.Store(t, fields)
+	return fields
+}
+
+func walkScanFields(t reflect.Type, index []int, prefix []string, visit func(prefix []string, f Field)) {
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		if !structField.IsExported() {
+			continue
+		}
+		if _, ok := relationTag(structField); ok {
+			continue
+		}
+		fieldIndex := append(append([]int{}, index...), i)
+		tag, tagged := structField.Tag.Lookup(Name)
+		if tag == "-" {
+			continue
+		}
+		column, opts := Parse(tag)
+
+		if structField.Type.Kind() == reflect.Struct {
+			if tagged && column != "" && !IsScannable(structField.Type) {
+				walkScanFields(structField.Type, fieldIndex, append(append([]string{}, prefix...), column), visit)
+				continue
+			}
+			walkScanFields(structField.Type, fieldIndex, prefix, visit)
+		}
+
+		if !tagged || column == "" {
+			continue
+		}
+		visit(prefix, Field{Column: column, Index: fieldIndex, Opts: opts})
+	}
+}
+
+// RelationField returns the field of the struct type t tagged with `sqx:"rel=<name>"`. Embedded structs are searched as
+// well.
+func RelationField(t reflect.Type, name string) (Field, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		if !structField.IsExported() {
+			continue
+		}
+		if rel, ok := relationTag(structField); ok {
+			if rel == name {
+				return Field{Index: []int{i}}, true
+			}
+			continue
+		}
+		if structField.Anonymous && structField.Type.Kind() == reflect.Struct {
+			if f, ok := RelationField(structField.Type, name); ok {
+				f.Index = append([]int{i}, f.Index...)
+				return f, true
+			}
+		}
+	}
+	return Field{}, false
+}
+
+// relationTag returns the relation name in the sqx tag of f, if it has one.
+func relationTag(f reflect.StructField) (string, bool) {
+	tag, ok := f.Tag.Lookup(RelationName)
+	if !ok {
+		return "", false
+	}
+	for _, opt := range strings.Split(tag, ",") {
+		if name := strings.TrimPrefix(strings.TrimSpace(opt), "rel="); name != strings.TrimSpace(opt) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// structValue dereferences v, which must be a pointer to a struct.
+func structValue(v any) (reflect.Value, error) {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr {
+		return reflect.Value{}, fmt.Errorf("%q must be a pointer to a struct: %w", value.Kind().String(), scan.ErrNotAStructPointer)
+	}
+	value = value.Elem()
+	if value.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("%q must be a pointer to a struct: %w", value.Kind().String(), scan.ErrNotAStructPointer)
+	}
+	return value, nil
+}
+
+// Columns returns the column names of the db-tagged fields of v, which must be a pointer to a struct. Any column
+// listed in excluded is omitted.
+func Columns(v any, excluded ...string) ([]string, error) {
+	value, err := structValue(v)
+	if err != nil {
+		return nil, fmt.Errorf("columns: %w", err)
+	}
+	fields := Fields(value.Type())
+	cols := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if !contains(excluded, f.Column) {
+			cols = append(cols, f.Column)
+		}
+	}
+	return cols, nil
+}
+
+// ColumnsWithOption returns the column names of the db-tagged fields of v that carry the given tag option.
+func ColumnsWithOption(v any, opt string) []string {
+	value, err := structValue(v)
+	if err != nil {
+		return nil
+	}
+	var cols []string
+	for _, f := range Fields(value.Type()) {
+		if f.Opts.Has(opt) {
+			cols = append(cols, f.Column)
+		}
+	}
+	return cols
+}
+
+// Values returns the values of the fields of v corresponding to cols, which must be a pointer to a struct.
+func Values(cols []string, v any) ([]any, error) {
+	value, err := structValue(v)
+	if err != nil {
+		return nil, fmt.Errorf("values: %w", err)
+	}
+	fields := Fields(value.Type())
+	vals := make([]any, len(cols))
+	for i, col := range cols {
+		f, ok := findField(fields, col)
+		if !ok {
+			return nil, fmt.Errorf("field %T.%q either does not exist or is unexported: %w", v, col, scan.ErrStructFieldMissing)
+		}
+		vals[i] = value.FieldByIndex(f.Index).Interface()
+	}
+	return vals, nil
+}
+
+func findField(fields []Field, column string) (Field, bool) {
+	for _, f := range fields {
+		if f.Column == column {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// isSupportedColumnType reports whether t is a primitive type (or a pointer, slice or array of one) that can be
+// written to the database directly.
+func isSupportedColumnType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Float32, reflect.Float64, reflect.Interface,
+		reflect.String:
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return isSupportedColumnType(t.Elem())
+	default:
+		return false
+	}
+}
+
+var (
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+)
+
+// IsScannable reports whether a single column can be scanned into a value of type t, e.g. time.Time or sql.NullString.
+func IsScannable(t reflect.Type) bool {
+	return isValidSQLValueType(t) || reflect.PtrTo(t).Implements(scannerType)
+}
+
+// isValidSQLValueType reports whether values of type t can be converted to a driver.Value, either because they already
+// are one (e.g. time.Time) or because t implements driver.Valuer.
+func isValidSQLValueType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		return isValidSQLValueType(t.Elem())
+	}
+	if driver.IsValue(reflect.Zero(t).Interface()) {
+		return true
+	}
+	return t.Implements(valuerType) || reflect.PtrTo(t).Implements(valuerType)
+}
@@ -0,0 +1,24 @@
+// Package schedule holds the timing helpers shared by the queue and outbox packages, which store when rows become due
+// in DATETIME(6) columns.
+package schedule
+
+import "time"
+
+// Backoff returns the delay before the given attempt, starting from 1: base for the first attempt, doubling for each
+// further attempt up to max. A max of zero or less means no cap.
+func Backoff(attempt int, base, max time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempt && (max <= 0 || delay < max); i++ {
+		delay *= 2
+	}
+	if max > 0 && delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// Now returns the current time in UTC, truncated to the precision of a DATETIME(6) column so that values read back
+// from the database compare equal to the values written.
+func Now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
@@ -0,0 +1,227 @@
+package sqx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultLoaderWait is how long a Loader collects keys before running a batch, unless LoaderOptions.Wait is set.
+	DefaultLoaderWait = 2 * time.Millisecond
+	// DefaultLoaderMaxBatch is the most keys a Loader loads in one query, unless LoaderOptions.MaxBatch is set.
+	DefaultLoaderMaxBatch = 100
+)
+
+// LoaderOptions configure a Loader.
+type LoaderOptions struct {
+	// Wait is how long a batch collects keys after its first key, before it is run.
+	Wait time.Duration
+	// MaxBatch is the most keys in a batch. A full batch is run without waiting.
+	MaxBatch int
+}
+
+// Loader batches the lookups of many callers into a single query, e.g. for GraphQL resolvers which each load one row
+// by ID. Keys passed to Load within a short window are collected into one SELECT with a `column IN (...)` clause, and
+// the rows are handed back to each caller by key.
+//
+// Keys are only batched with the keys of the same request: callers whose ctx shares a cache from WithLoaderCache are
+// batched together, and so are callers which pass the same ctx if it has no cache. A batch is run with the ctx of the
+// first caller to join it. Results are cached for the lifetime of a ctx prepared with WithLoaderCache.
+type Loader[K comparable, T any] struct {
+	query  func(ctx context.Context) SelectBuilder[T]
+	column string
+	key    func(T) K
+	opts   LoaderOptions
+
+	mu      sync.Mutex
+	batches map[any]*loaderBatch[K, T]
+}
+
+// NewLoader creates a Loader which loads rows with query, restricted to the keys being loaded with
+// `Where(Eq{column: keys})`. key returns the key of a loaded row, which must match the value of column.
+//
+//	users := sqx.NewLoader(func(ctx context.Context) sqx.SelectBuilder[User] {
+//		return sqx.Read[User](ctx).Select("*").From("users")
+//	}, "id", func(u User) string { return u.ID }, sqx.LoaderOptions{})
+func NewLoader[K comparable, T any](
+	query func(ctx context.Context) SelectBuilder[T], column string, key func(T) K, opts LoaderOptions,
+) *Loader[K, T] {
+	if opts.Wait <= 0 {
+		opts.Wait = DefaultLoaderWait
+	}
+	if opts.MaxBatch <= 0 {
+		opts.MaxBatch = DefaultLoaderMaxBatch
+	}
+	return &Loader[K, T]{query: query, column: column, key: key, opts: opts, batches: map[any]*loaderBatch[K, T]{}}
+}
+
+// Load returns the row with the given key, or sql.ErrNoRows if there is none. It waits for the batch the key is added
+// to, unless the row is already cached in ctx.
+func (l *Loader[K, T]) Load(ctx context.Context, key K) (*T, error) {
+	cache := loaderCacheFromContext(ctx)
+	var result *loaderResult[T]
+	if cache != nil {
+		result = cache.get(l, key, func() any { return l.enqueue(ctx, key) }).(*loaderResult[T])
+	} else {
+		result = l.enqueue(ctx, key)
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-result.done:
+	}
+	if result.err != nil && !errors.Is(result.err, sql.ErrNoRows) && cache != nil {
+		// Only cache rows and missing rows, so that a transient error can be retried.
+		cache.evict(l, key, result)
+	}
+	return result.value, result.err
+}
+
+// LoadMany loads several keys in the same batch. The returned slices are in the order of keys.
+func (l *Loader[K, T]) LoadMany(ctx context.Context, keys []K) ([]*T, []error) {
+	values := make([]*T, len(keys))
+	errs := make([]error, len(keys))
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		wg.Add(1)
+		go func(i int, key K) {
+			defer wg.Done()
+			values[i], errs[i] = l.Load(ctx, key)
+		}(i, key)
+	}
+	wg.Wait()
+	return values, errs
+}
+
+// enqueue adds key to the current batch of the request ctx belongs to, starting a new batch if there is none.
+func (l *Loader[K, T]) enqueue(ctx context.Context, key K) *loaderResult[T] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	request := loaderRequest(ctx)
+	batch, ok := l.batches[request]
+	if !ok {
+		batch = &loaderBatch[K, T]{ctx: ctx, request: request, results: map[K]*loaderResult[T]{}}
+		batch.timer = time.AfterFunc(l.opts.Wait, func() { l.dispatch(batch) })
+		l.batches[request] = batch
+	}
+	if result, ok := batch.results[key]; ok {
+		return result
+	}
+	result := &loaderResult[T]{done: make(chan struct{})}
+	batch.keys = append(batch.keys, key)
+	batch.results[key] = result
+
+	if len(batch.keys) >= l.opts.MaxBatch {
+		batch.timer.Stop()
+		delete(l.batches, request)
+		go l.run(batch)
+	}
+	return result
+}
+
+// dispatch runs batch once its wait is over, unless it was already run because it was full.
+func (l *Loader[K, T]) dispatch(batch *loaderBatch[K, T]) {
+	l.mu.Lock()
+	if l.batches[batch.request] != batch {
+		l.mu.Unlock()
+		return
+	}
+	delete(l.batches, batch.request)
+	l.mu.Unlock()
+	l.run(batch)
+}
+
+// run loads the keys of batch and hands each result to its callers.
+func (l *Loader[K, T]) run(batch *loaderBatch[K, T]) {
+	rows, err := l.query(batch.ctx).Where(Eq{l.column: batch.keys}).All()
+	for i := range rows {
+		if result, ok := batch.results[l.key(rows[i])]; ok && result.value == nil {
+			result.value = &rows[i]
+		}
+	}
+	for _, result := range batch.results {
+		switch {
+		case err != nil:
+			result.err = err
+		case result.value == nil:
+			result.err = sql.ErrNoRows
+		}
+		close(result.done)
+	}
+}
+
+type loaderBatch[K comparable, T any] struct {
+	ctx     context.Context
+	request any
+	keys    []K
+	results map[K]*loaderResult[T]
+	timer   *time.Timer
+}
+
+// loaderResult is the outcome of loading one key. value and err are set before done is closed.
+type loaderResult[T any] struct {
+	done  chan struct{}
+	value *T
+	err   error
+}
+
+type loaderCacheKey struct{}
+
+// loaderCache holds the results of every Loader used with a ctx, keyed by loader and key.
+type loaderCache struct {
+	mu      sync.Mutex
+	results map[loaderCacheEntry]any
+}
+
+type loaderCacheEntry struct {
+	loader any
+	key    any
+}
+
+// WithLoaderCache returns a copy of ctx in which Loaders cache their results, so that each key is loaded at most once.
+// Call it once per request, such as in the middleware that handles a request, so that the cache does not outlive it.
+func WithLoaderCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, loaderCacheKey{}, &loaderCache{results: map[loaderCacheEntry]any{}})
+}
+
+func loaderCacheFromContext(ctx context.Context) *loaderCache {
+	cache, _ := ctx.Value(loaderCacheKey{}).(*loaderCache)
+	return cache
+}
+
+// loaderRequest identifies the request that ctx belongs to, for batching: its loader cache if it has one, or else ctx
+// itself.
+func loaderRequest(ctx context.Context) any {
+	if cache := loaderCacheFromContext(ctx); cache != nil {
+		return cache
+	}
+	return ctx
+}
+
+// get returns the cached result for key, or caches the result of load if there is none.
+func (c *loaderCache) get(loader, key any, load func() any) any {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := loaderCacheEntry{loader: loader, key: key}
+	if result, ok := c.results[entry]; ok {
+		return result
+	}
+	result := load()
+	c.results[entry] = result
+	return result
+}
+
+// evict removes the cached result for key, if it is still result.
+func (c *loaderCache) evict(loader, key, result any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := loaderCacheEntry{loader: loader, key: key}
+	if c.results[entry] == result {
+		delete(c.results, entry)
+	}
+}
@@ -0,0 +1,148 @@
+package sqx_test
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stytchauth/sqx"
+	"github.com/stytchauth/sqx/sqxtest"
+)
+
+func newWidgetLoader(fake *sqxtest.Fake, opts sqx.LoaderOptions) *sqx.Loader[string, Widget] {
+	return sqx.NewLoader(func(ctx context.Context) sqx.SelectBuilder[Widget] {
+		return sqx.Read[Widget](ctx).WithQueryable(fake).Select("*").From("widgets")
+	}, "widget_id", func(w Widget) string { return w.ID }, opts)
+}
+
+func TestLoader(t *testing.T) {
+	ctx := context.Background()
+	w1 := Widget{ID: "w1", Status: "great"}
+	w2 := Widget{ID: "w2", Status: "fine"}
+
+	t.Run("Batches keys into one query", func(t *testing.T) {
+		fake := sqxtest.New()
+		fake.ExpectQuery("SELECT * FROM widgets WHERE widget_id IN (?,?,?)").
+			WithArgs(sqxtest.AnyArg(), sqxtest.AnyArg(), sqxtest.AnyArg()).
+			WillReturnRows(sqxtest.RowsFromItems(w1, w2))
+		loader := newWidgetLoader(fake, sqx.LoaderOptions{Wait: 20 * time.Millisecond})
+
+		widgets, errs := loader.LoadMany(ctx, []string{"w2", "missing", "w1", "w2"})
+		assert.Equal(t, []*Widget{&w2, nil, &w1, &w2}, widgets)
+		assert.NoError(t, errs[0])
+		assert.ErrorIs(t, errs[1], sql.ErrNoRows)
+		assert.NoError(t, errs[2])
+		fake.AssertExpectations(t)
+	})
+
+	t.Run("Runs full batches without waiting", func(t *testing.T) {
+		fake := sqxtest.New()
+		fake.ExpectQuery("SELECT * FROM widgets WHERE widget_id IN (?,?)").WillReturnRows(sqxtest.RowsFromItems(w1, w2))
+		fake.ExpectQuery("SELECT * FROM widgets WHERE widget_id IN (?)").WithArgs("w3")
+		loader := newWidgetLoader(fake, sqx.LoaderOptions{Wait: time.Hour, MaxBatch: 2})
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_, errs := loader.LoadMany(ctx, []string{"w1", "w2"})
+			assert.Equal(t, []error{nil, nil}, errs)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("a full batch should run immediately")
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		_, err := loader.Load(ctx, "w3")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("Caches results in the ctx", func(t *testing.T) {
+		fake := sqxtest.New()
+		fake.ExpectQuery("SELECT * FROM widgets WHERE widget_id IN (?,?)").WillReturnRows(sqxtest.RowsFromItems(w1))
+		fake.ExpectQuery("SELECT * FROM widgets WHERE widget_id IN (?)").WithArgs("w1").WillReturnRows(sqxtest.RowsFromItems(w1))
+		loader := newWidgetLoader(fake, sqx.LoaderOptions{})
+
+		reqCtx := sqx.WithLoaderCache(ctx)
+		loader.LoadMany(reqCtx, []string{"w1", "missing"})
+		widget, err := loader.Load(reqCtx, "w1")
+		require.NoError(t, err)
+		assert.Equal(t, &w1, widget)
+		_, err = loader.Load(reqCtx, "missing")
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.Len(t, fake.Calls(), 1)
+
+		_, err = loader.Load(sqx.WithLoaderCache(ctx), "w1")
+		require.NoError(t, err)
+		assert.Len(t, fake.Calls(), 2, "each request has its own cache")
+		fake.AssertExpectations(t)
+	})
+
+	t.Run("Does not cache errors", func(t *testing.T) {
+		fake := sqxtest.New()
+		fake.ExpectQuery("SELECT * FROM widgets WHERE widget_id IN (?)").WillReturnError(assert.AnError)
+		fake.ExpectQuery("SELECT * FROM widgets WHERE widget_id IN (?)").WillReturnRows(sqxtest.RowsFromItems(w1))
+		loader := newWidgetLoader(fake, sqx.LoaderOptions{})
+
+		reqCtx := sqx.WithLoaderCache(ctx)
+		_, err := loader.Load(reqCtx, "w1")
+		assert.ErrorIs(t, err, assert.AnError)
+		widget, err := loader.Load(reqCtx, "w1")
+		require.NoError(t, err)
+		assert.Equal(t, &w1, widget)
+		fake.AssertExpectations(t)
+	})
+	t.Run("Batches the keys of each request separately", func(t *testing.T) {
+		fake := sqxtest.New()
+		for i := 0; i < 4; i++ {
+			fake.ExpectQuery("SELECT * FROM widgets WHERE project_id = ? AND widget_id IN (?)").
+				WithArgs(sqxtest.AnyArg(), sqxtest.AnyArg()).
+				WillReturnRows(sqxtest.RowsFromItems(w1, w2))
+		}
+		loader := sqx.NewLoader(func(ctx context.Context) sqx.SelectBuilder[Widget] {
+			return sqx.Read[Widget](ctx).
+				WithQueryable(fake).
+				Select("*").
+				From("widgets").
+				Where(sqx.ScopeFromContext(ctx))
+		}, "widget_id", func(w Widget) string { return w.ID }, sqx.LoaderOptions{Wait: 20 * time.Millisecond})
+
+		ctxA := sqx.WithScope(ctx, sqx.Eq{"project_id": "a"})
+		ctxB := sqx.WithScope(ctx, sqx.Eq{"project_id": "b"})
+		loads := []struct {
+			ctx context.Context
+			key string
+		}{
+			{ctxA, "w1"},
+			{ctxB, "w2"},
+			{sqx.WithLoaderCache(ctxA), "w2"},
+			{sqx.WithLoaderCache(ctxB), "w1"},
+		}
+		var wg sync.WaitGroup
+		for _, load := range loads {
+			wg.Add(1)
+			go func(ctx context.Context, key string) {
+				defer wg.Done()
+				widget, err := loader.Load(ctx, key)
+				if assert.NoError(t, err) {
+					assert.Equal(t, key, widget.ID)
+				}
+			}(load.ctx, load.key)
+		}
+		wg.Wait()
+
+		var args [][]any
+		for _, call := range fake.Calls() {
+			args = append(args, call.Args)
+		}
+		assert.ElementsMatch(t, [][]any{{"a", "w1"}, {"b", "w2"}, {"a", "w2"}, {"b", "w1"}}, args)
+		fake.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,89 @@
+package sqx
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrLockOutsideTx is returned when a SelectBuilder with a locking clause, such as ForUpdate, is run against a
+// Queryable that is not a *sql.Tx, or a Wrapper around one. Outside a transaction, row locks are released as soon as
+// the statement finishes, which is almost certainly a bug.
+var ErrLockOutsideTx = errors.New("locking reads must be run in a transaction")
+
+// rowLock is the locking clause of a SelectBuilder.
+type rowLock struct {
+	strength string
+	of       []string
+	wait     string
+}
+
+// String renders the locking clause, or "" if there is none.
+func (l rowLock) String() string {
+	if l.strength == "" {
+		return ""
+	}
+	clause := l.strength
+	if len(l.of) > 0 {
+		clause += " OF " + strings.Join(l.of, ", ")
+	}
+	if l.wait != "" {
+		clause += " " + l.wait
+	}
+	return clause
+}
+
+// validate returns an error if the options were set without a lock strength.
+func (l rowLock) validate() error {
+	if l.strength == "" && (len(l.of) > 0 || l.wait != "") {
+		return errors.New("SkipLocked, NoWait and Of require ForUpdate or ForShare")
+	}
+	return nil
+}
+
+// checkQueryable returns ErrLockOutsideTx if the lock is set and queryable is not a transaction.
+func (l rowLock) checkQueryable(queryable Queryable) error {
+	if l.strength == "" {
+		return nil
+	}
+	if !inTx(queryable) {
+		return ErrLockOutsideTx
+	}
+	return nil
+}
+
+// ForUpdate locks the selected rows against updates and locking reads by other transactions until the transaction
+// ends. The query must be run against a *sql.Tx.
+//
+// The locking clause is always rendered at the end of the query, after any UNION ALL, LIMIT or Suffix clauses.
+func (b SelectBuilder[T]) ForUpdate() SelectBuilder[T] {
+	b.lock.strength = "FOR UPDATE"
+	return b
+}
+
+// ForShare locks the selected rows against updates by other transactions until the transaction ends, while still
+// allowing other transactions to read them with ForShare. The query must be run against a *sql.Tx.
+func (b SelectBuilder[T]) ForShare() SelectBuilder[T] {
+	b.lock.strength = "FOR SHARE"
+	return b
+}
+
+// SkipLocked skips rows that are locked by other transactions instead of waiting for them, which lets several workers
+// claim different rows from the same table. Requires ForUpdate or ForShare.
+func (b SelectBuilder[T]) SkipLocked() SelectBuilder[T] {
+	b.lock.wait = "SKIP LOCKED"
+	return b
+}
+
+// NoWait makes the query fail immediately if a row is locked by another transaction, instead of waiting for it.
+// Requires ForUpdate or ForShare.
+func (b SelectBuilder[T]) NoWait() SelectBuilder[T] {
+	b.lock.wait = "NOWAIT"
+	return b
+}
+
+// Of restricts the lock to rows from the given tables (or their aliases) in a query with joins. Requires ForUpdate or
+// ForShare.
+func (b SelectBuilder[T]) Of(tables ...string) SelectBuilder[T] {
+	b.lock.of = append(append([]string{}, b.lock.of...), tables...)
+	return b
+}
@@ -0,0 +1,82 @@
+package sqx_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stytchauth/sqx"
+	"github.com/stytchauth/sqx/sqxtest"
+)
+
+func TestLockingReads(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Renders the locking clause at the end of the query", func(t *testing.T) {
+		query, args, err := sqx.Read[Widget](ctx).
+			Select("*").
+			From("jobs").
+			Where(sqx.Eq{"status": "pending"}).
+			ForUpdate().
+			SkipLocked().
+			OrderBy("id").
+			Limit(10).
+			ToSql()
+		require.NoError(t, err)
+		assert.Equal(t, "SELECT * FROM jobs WHERE status = ? ORDER BY id LIMIT 10 FOR UPDATE SKIP LOCKED", query)
+		assert.Equal(t, []any{"pending"}, args)
+
+		query, _, err = sqx.Read[Widget](ctx).
+			Select("j.*").
+			From("jobs j").
+			Join("queues q ON q.id = j.queue_id").
+			ForShare().
+			Of("j").
+			NoWait().
+			ToSql()
+		require.NoError(t, err)
+		assert.Equal(t, "SELECT j.* FROM jobs j JOIN queues q ON q.id = j.queue_id FOR SHARE OF j NOWAIT", query)
+	})
+
+	t.Run("Renders the locking clause after UNION ALL", func(t *testing.T) {
+		query, _, err := sqx.Read[Widget](ctx).
+			Select("*").
+			From("jobs").
+			ForUpdate().
+			UnionAll(sqx.Read[Widget](ctx).Select("*").From("archived_jobs")).
+			ToSql()
+		require.NoError(t, err)
+		assert.Equal(t, "SELECT * FROM jobs UNION ALL (SELECT * FROM archived_jobs) FOR UPDATE", query)
+	})
+
+	t.Run("Requires a lock strength for lock options", func(t *testing.T) {
+		_, _, err := sqx.Read[Widget](ctx).Select("*").From("jobs").SkipLocked().ToSql()
+		assert.Error(t, err)
+	})
+
+	t.Run("Requires a transaction", func(t *testing.T) {
+		fake := sqxtest.New()
+		_, err := sqx.Read[Widget](ctx).WithQueryable(fake).Select("*").From("jobs").ForUpdate().All()
+		assert.ErrorIs(t, err, sqx.ErrLockOutsideTx)
+		assert.Empty(t, fake.Calls())
+
+		tx, err := fake.DB().BeginTx(ctx, nil)
+		require.NoError(t, err)
+		defer tx.Rollback()
+		_, err = sqx.Read[Widget](ctx).WithQueryable(tx).Select("*").From("jobs").ForUpdate().All()
+		require.NoError(t, err)
+		assert.Equal(t, "SELECT * FROM jobs FOR UPDATE", fake.Calls()[0].Query)
+	})
+
+	t.Run("Accepts a wrapped transaction", func(t *testing.T) {
+		fake := sqxtest.New()
+		tx, err := fake.DB().BeginTx(ctx, nil)
+		require.NoError(t, err)
+		defer tx.Rollback()
+		_, err = sqx.Read[Widget](ctx).WithQueryable(sqxtest.InjectFaults(tx)).Select("*").From("jobs").ForUpdate().All()
+		require.NoError(t, err)
+		assert.Equal(t, "SELECT * FROM jobs FOR UPDATE", fake.Calls()[0].Query)
+	})
+}
@@ -0,0 +1,146 @@
+package sqx
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+)
+
+// ReadMaps is the entrypoint for Select builders which scan each row into a map of column name to value, for queries
+// whose columns are not known in advance. It is shorthand for Read[map[string]any].
+//
+// Values have the Go type the driver reports as the column's ScanType, e.g. int8 for a MySQL TINYINT. NULL is nil,
+// sql.Null* values are unwrapped, and text columns are strings rather than []byte.
+func ReadMaps(ctx context.Context) typedRunCtx[map[string]any] {
+	return Read[map[string]any](ctx)
+}
+
+// ColumnType describes a column of a result set. See sql.ColumnType.
+type ColumnType struct {
+	Name string
+	// DatabaseType is the database type of the column, e.g. "VARCHAR" or "BIGINT". It is empty if the driver does not
+	// report it.
+	DatabaseType string
+	// Nullable reports whether the column may be NULL. It is false if the driver does not report it.
+	Nullable bool
+	// ScanType is the Go type the driver scans the column into.
+	ScanType reflect.Type
+}
+
+// ColumnTypes returns the types of the columns in the result set of the query. It runs the query wrapped in
+// SELECT * FROM (...) AS t LIMIT 0, so that the database returns no rows.
+func (b SelectBuilder[T]) ColumnTypes() ([]ColumnType, error) {
+	b.noRows = true
+	return queryRows(b, func(rows *sql.Rows) ([]ColumnType, error) {
+		defer rows.Close()
+		return columnTypes(rows)
+	})
+}
+
+// AllWithColumnTypes is like All, but also returns the types of the columns in the result set, e.g. to render the
+// results of ReadMaps in column order.
+func (b SelectBuilder[T]) AllWithColumnTypes() ([]T, []ColumnType, error) {
+	var types []ColumnType
+	dest, err := queryRows(b, func(rows *sql.Rows) ([]T, error) {
+		var err error
+		if types, err = columnTypes(rows); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		return scanRows[T](rows)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := b.loadRelations(dest); err != nil {
+		return nil, nil, err
+	}
+	return dest, types, nil
+}
+
+func columnTypes(rows *sql.Rows) ([]ColumnType, error) {
+	sqlTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	types := make([]ColumnType, len(sqlTypes))
+	for i, t := range sqlTypes {
+		nullable, _ := t.Nullable()
+		types[i] = ColumnType{
+			Name:         t.Name(),
+			DatabaseType: t.DatabaseTypeName(),
+			Nullable:     nullable,
+			ScanType:     t.ScanType(),
+		}
+	}
+	return types, nil
+}
+
+var (
+	mapType      = reflect.TypeOf(map[string]any{})
+	rawBytesType = reflect.TypeOf(sql.RawBytes{})
+)
+
+// scanMaps scans every row in rows into a map of column name to value, appending them to dest. T must be
+// map[string]any.
+func scanMaps[T any](dest []T, rows *sql.Rows, cols []string) ([]T, error) {
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		pointers := mapPointers(types)
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		item := make(map[string]any, len(cols))
+		for i, col := range cols {
+			item[col] = mapValue(pointers[i], types[i])
+		}
+		dest = append(dest, any(item).(T))
+	}
+	return dest, rows.Err()
+}
+
+// mapPointers returns one scan destination per column of a row scanned into a map. Columns are scanned into their
+// ScanType where the driver reports one, so that e.g. a BIGINT read over MySQL's text protocol is an int64 rather
+// than []byte.
+func mapPointers(types []*sql.ColumnType) []any {
+	pointers := make([]any, len(types))
+	for i, t := range types {
+		scanType := t.ScanType()
+		if scanType == nil || scanType.Kind() == reflect.Interface || scanType == rawBytesType {
+			pointers[i] = new(any)
+		} else {
+			pointers[i] = reflect.New(scanType).Interface()
+		}
+	}
+	return pointers
+}
+
+// mapValue returns the value scanned into pointer, unwrapping sql.Null* types and converting text to strings.
+func mapValue(pointer any, t *sql.ColumnType) any {
+	value := reflect.ValueOf(pointer).Elem().Interface()
+	if valuer, ok := value.(driver.Valuer); ok {
+		if v, err := valuer.Value(); err == nil {
+			value = v
+		}
+	}
+	if b, ok := value.([]byte); ok && isTextType(t.DatabaseTypeName()) {
+		return string(b)
+	}
+	return value
+}
+
+// isTextType reports whether values of the database type are text, which drivers often return as []byte.
+func isTextType(databaseType string) bool {
+	databaseType = strings.ToUpper(databaseType)
+	for _, text := range []string{"CHAR", "TEXT", "ENUM", "SET", "JSON", "DECIMAL", "NUMERIC", "UUID"} {
+		if strings.Contains(databaseType, text) {
+			return true
+		}
+	}
+	return false
+}
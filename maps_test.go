@@ -0,0 +1,100 @@
+package sqx_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stytchauth/sqx"
+	"github.com/stytchauth/sqx/sqxtest"
+)
+
+func TestReadMaps(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Scans rows into maps", func(t *testing.T) {
+		tx := Tx(t)
+		setupTestWidgetsTable(t, tx)
+		dbWidget := newDBWidget()
+		widget := Widget{ID: "w1", Status: "great", Enabled: true}
+		require.NoError(t, dbWidget.Create(ctx, tx, &widget))
+
+		expected := []map[string]any{{"widget_id": "w1", "status": "great", "enabled": int8(1), "owner_id": nil}}
+		rows, err := sqx.ReadMaps(ctx).WithQueryable(tx).Select("*").From("sqx_widgets_test").All()
+		require.NoError(t, err)
+		assert.Equal(t, expected, rows, "text protocol")
+
+		rows, err = sqx.ReadMaps(ctx).
+			WithQueryable(tx).
+			Select("*").
+			From("sqx_widgets_test").
+			Where(sqx.Eq{"widget_id": "w1"}).
+			All()
+		require.NoError(t, err)
+		assert.Equal(t, expected, rows, "binary protocol")
+
+		row, err := sqx.ReadMaps(ctx).WithQueryable(tx).Select("COUNT(*) AS n").From("sqx_widgets_test").One()
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"n": int64(1)}, *row)
+	})
+
+	t.Run("Returns column types", func(t *testing.T) {
+		tx := Tx(t)
+		setupTestWidgetsTable(t, tx)
+
+		types, err := sqx.Read[Widget](ctx).
+			WithQueryable(tx).
+			Select("widget_id", "owner_id").
+			From("sqx_widgets_test").
+			ColumnTypes()
+		require.NoError(t, err)
+		require.Len(t, types, 2)
+		assert.Equal(t, "widget_id", types[0].Name)
+		assert.Equal(t, "VARCHAR", types[0].DatabaseType)
+		assert.False(t, types[0].Nullable)
+		assert.Equal(t, "owner_id", types[1].Name)
+		assert.True(t, types[1].Nullable)
+	})
+
+	t.Run("Returns column types without reading rows", func(t *testing.T) {
+		fake := sqxtest.New()
+		fake.ExpectQuery("SELECT * FROM (SELECT b, a FROM things WHERE a = ? LIMIT 10) AS t LIMIT 0").
+			WithArgs(1).
+			WillReturnRows(sqxtest.NewRows("b", "a"))
+		fake.ExpectQuery("SELECT * FROM (SELECT b, a FROM things UNION ALL (SELECT b, a FROM others)) AS t LIMIT 0").
+			WillReturnRows(sqxtest.NewRows("b", "a"))
+
+		types, err := sqx.ReadMaps(ctx).
+			WithQueryable(fake).
+			Select("b", "a").
+			From("things").
+			Where(sqx.Eq{"a": 1}).
+			Limit(10).
+			ColumnTypes()
+		require.NoError(t, err)
+		require.Len(t, types, 2)
+		assert.Equal(t, "b", types[0].Name)
+		assert.Equal(t, "a", types[1].Name)
+
+		others := sqx.ReadMaps(ctx).Select("b", "a").From("others")
+		types, err = sqx.ReadMaps(ctx).WithQueryable(fake).Select("b", "a").From("things").UnionAll(others).ColumnTypes()
+		require.NoError(t, err)
+		require.Len(t, types, 2)
+		fake.AssertExpectations(t)
+	})
+
+	t.Run("Returns rows with their column types", func(t *testing.T) {
+		fake := sqxtest.New()
+		fake.ExpectQuery("SELECT b, a FROM things").
+			WillReturnRows(sqxtest.NewRows("b", "a").AddRow("x", 1).AddRow(nil, 2))
+
+		rows, types, err := sqx.ReadMaps(ctx).WithQueryable(fake).Select("b", "a").From("things").AllWithColumnTypes()
+		require.NoError(t, err)
+		assert.Equal(t, []map[string]any{{"b": "x", "a": int64(1)}, {"b": nil, "a": int64(2)}}, rows)
+		require.Len(t, types, 2)
+		assert.Equal(t, "b", types[0].Name)
+		assert.Equal(t, "a", types[1].Name)
+	})
+}
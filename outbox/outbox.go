@@ -0,0 +1,266 @@
+// Package outbox implements the transactional outbox pattern on top of sqx. Events are added to an outbox table in the
+// same transaction as the domain rows they describe, so either both are written or neither is. A Relay then publishes
+// them to a message broker, retrying failures, and publishes the events of each aggregate key in the order they were
+// added.
+//
+// The table must have the following columns:
+//
+//	CREATE TABLE outbox (
+//		id               BIGINT AUTO_INCREMENT PRIMARY KEY,
+//		aggregate_key    VARCHAR(255) NOT NULL,
+//		topic            VARCHAR(255) NOT NULL,
+//		payload          BLOB NOT NULL,
+//		status           VARCHAR(16) NOT NULL,
+//		attempts         INT NOT NULL,
+//		next_attempt_at  DATETIME(6) NOT NULL,
+//		last_error       TEXT NULL,
+//		created_at       DATETIME(6) NOT NULL,
+//		delivered_at     DATETIME(6) NULL,
+//		INDEX outbox_status_aggregate_key (status, aggregate_key, id)
+//	);
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/stytchauth/sqx"
+	"github.com/stytchauth/sqx/internal/schedule"
+)
+
+// DefaultTable is the outbox table used by Add, and by a Relay unless Options.Table is set.
+const DefaultTable = "outbox"
+
+const (
+	// StatusPending is the status of events which have not been published yet.
+	StatusPending = "pending"
+	// StatusDelivered is the status of events which were published.
+	StatusDelivered = "delivered"
+	// StatusFailed is the status of events which were given up on after Options.MaxAttempts.
+	StatusFailed = "failed"
+)
+
+const (
+	// DefaultBatchSize is how many events a Relay publishes per transaction, unless Options.BatchSize is set.
+	DefaultBatchSize = 100
+	// DefaultPollInterval is how long a Relay waits after finding no events to publish, unless Options.PollInterval
+	// is set.
+	DefaultPollInterval = time.Second
+)
+
+// Event is an event to be published.
+type Event struct {
+	// Key identifies the aggregate the event is about, e.g. a user ID. Events with the same Key are published in the
+	// order they were added.
+	Key string
+	// Topic is where the event is published to.
+	Topic   string
+	Payload []byte
+}
+
+// Message is an event read back from the outbox by a Relay.
+type Message struct {
+	ID int64
+	Event
+	// Attempts is the number of times publishing the event has failed before.
+	Attempts  int
+	CreatedAt time.Time
+}
+
+// row is an event as stored in the outbox table.
+type row struct {
+	ID            int64      `db:"id"`
+	Key           string     `db:"aggregate_key"`
+	Topic         string     `db:"topic"`
+	Payload       []byte     `db:"payload"`
+	Status        string     `db:"status"`
+	Attempts      int        `db:"attempts"`
+	NextAttemptAt time.Time  `db:"next_attempt_at"`
+	LastError     *string    `db:"last_error"`
+	CreatedAt     time.Time  `db:"created_at"`
+	DeliveredAt   *time.Time `db:"delivered_at"`
+}
+
+// Add adds events to DefaultTable in tx. They are published by a Relay once tx commits.
+func Add(ctx context.Context, tx *sql.Tx, events ...Event) error {
+	return AddTo(ctx, tx, DefaultTable, events...)
+}
+
+// AddTo adds events to table in tx. They are published by a Relay once tx commits.
+func AddTo(ctx context.Context, tx *sql.Tx, table string, events ...Event) error {
+	created := schedule.Now()
+	for _, event := range events {
+		err := sqx.Write(ctx).
+			WithQueryable(tx).
+			Insert(table).
+			SetMap(sqx.ToSetMap(&row{
+				Key:           event.Key,
+				Topic:         event.Topic,
+				Payload:       event.Payload,
+				Status:        StatusPending,
+				NextAttemptAt: created,
+				CreatedAt:     created,
+			}, "id")).
+			Do()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Publisher publishes a message to a broker. If it returns an error, the message is retried after
+// Options.RetryDelay, and later events with the same key wait for it.
+type Publisher func(ctx context.Context, msg Message) error
+
+// Options configure a Relay.
+type Options struct {
+	// Table is the outbox table. If empty, DefaultTable is used.
+	Table string
+	// BatchSize is the maximum number of events published per transaction.
+	BatchSize int
+	// PollInterval is how long Run waits after finding no events to publish.
+	PollInterval time.Duration
+	// MaxAttempts is how many times publishing an event can fail before it is marked failed. A failed event no longer
+	// holds back later events with the same key. Zero means events are retried forever.
+	MaxAttempts int
+	// RetryDelay returns how long to wait before publishing an event again, given the number of times it has failed.
+	// If nil, DefaultRetryDelay is used.
+	RetryDelay func(attempts int) time.Duration
+}
+
+// DefaultRetryDelay waits one second after the first failure, and doubles the wait after each further failure, up to
+// an hour.
+func DefaultRetryDelay(attempts int) time.Duration {
+	return schedule.Backoff(attempts, time.Second, time.Hour)
+}
+
+// Relay publishes events from an outbox table. Several relays can run against the same table - each event is
+// published by a single relay, although it may be published more than once if a relay fails after publishing it but
+// before marking it delivered.
+type Relay struct {
+	db      *sql.DB
+	publish Publisher
+	opts    Options
+}
+
+// NewRelay creates a Relay which publishes events from db with publish.
+func NewRelay(db *sql.DB, publish Publisher, opts Options) *Relay {
+	if opts.Table == "" {
+		opts.Table = DefaultTable
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultPollInterval
+	}
+	if opts.RetryDelay == nil {
+		opts.RetryDelay = DefaultRetryDelay
+	}
+	return &Relay{db: db, publish: publish, opts: opts}
+}
+
+// Run publishes events until ctx is done, and then returns ctx.Err(). It returns early if reading from or writing to
+// the outbox fails; errors from the Publisher are retried instead.
+func (r *Relay) Run(ctx context.Context) error {
+	for {
+		published, err := r.RelayOnce(ctx)
+		if ctx.Err() != nil {
+			// Statements interrupted by ctx fail with driver errors, so report why the relay stopped instead.
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+		if published > 0 {
+			continue
+		}
+		timer := time.NewTimer(r.opts.PollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// RelayOnce publishes one batch of due events and returns how many were handed to the Publisher. At most one event per
+// key is published per batch: the oldest pending event for the key, if it is due.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Only the oldest pending event of each key is eligible, so that a later event is never published while an earlier
+	// one is locked by another relay or waiting to be retried. The subquery is not a locking read, so the status of the
+	// locked row is checked again in case another relay delivered it in the meantime.
+	rows, err := sqx.Read[row](ctx).
+		WithQueryable(tx).
+		Select("*").
+		From(r.opts.Table).
+		Where(sqx.Expr(
+			fmt.Sprintf("id IN (SELECT MIN(id) FROM %s WHERE status = ? GROUP BY aggregate_key)", r.opts.Table),
+			StatusPending,
+		)).
+		Where(sqx.Eq{"status": StatusPending}).
+		Where(sqx.LtOrEq{"next_attempt_at": schedule.Now()}).
+		OrderBy("id").
+		Limit(uint64(r.opts.BatchSize)).
+		ForUpdate().
+		SkipLocked().
+		All()
+	if err != nil || len(rows) == 0 {
+		return 0, err
+	}
+
+	for _, event := range rows {
+		msg := Message{
+			ID:        event.ID,
+			Event:     Event{Key: event.Key, Topic: event.Topic, Payload: event.Payload},
+			Attempts:  event.Attempts,
+			CreatedAt: event.CreatedAt,
+		}
+		if err := r.publish(ctx, msg); err != nil {
+			err = r.markFailed(ctx, tx, msg, err)
+		} else {
+			err = r.markDelivered(ctx, tx, msg)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(rows), nil
+}
+
+func (r *Relay) markDelivered(ctx context.Context, tx *sql.Tx, msg Message) error {
+	return sqx.Write(ctx).
+		WithQueryable(tx).
+		Update(r.opts.Table).
+		Set("status", StatusDelivered).
+		Set("delivered_at", schedule.Now()).
+		Where(sqx.Eq{"id": msg.ID}).
+		Do()
+}
+
+func (r *Relay) markFailed(ctx context.Context, tx *sql.Tx, msg Message, cause error) error {
+	attempts := msg.Attempts + 1
+	update := sqx.Write(ctx).
+		WithQueryable(tx).
+		Update(r.opts.Table).
+		Set("attempts", attempts).
+		Set("last_error", cause.Error()).
+		Where(sqx.Eq{"id": msg.ID})
+	if r.opts.MaxAttempts > 0 && attempts >= r.opts.MaxAttempts {
+		return update.Set("status", StatusFailed).Do()
+	}
+	return update.Set("next_attempt_at", schedule.Now().Add(r.opts.RetryDelay(attempts))).Do()
+}
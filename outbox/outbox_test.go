@@ -0,0 +1,194 @@
+package outbox_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stytchauth/sqx"
+	"github.com/stytchauth/sqx/outbox"
+)
+
+// setupOutboxTable opens a connection to the test database and creates an empty outbox table.
+func setupOutboxTable(t *testing.T) *sql.DB {
+	db, err := sql.Open("mysql", "sqx:sqx@tcp(localhost:4306)/sqx?parseTime=true")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Logf("Close DB connection: %s", err.Error())
+		}
+	})
+
+	_, err = db.Exec(`DROP TABLE IF EXISTS sqx_outbox_test;`)
+	require.NoError(t, err, "Did you remember to run make services?")
+	_, err = db.Exec(`
+		CREATE TABLE sqx_outbox_test (
+			id               BIGINT AUTO_INCREMENT PRIMARY KEY,
+			aggregate_key    VARCHAR(255) NOT NULL,
+			topic            VARCHAR(255) NOT NULL,
+			payload          BLOB NOT NULL,
+			status           VARCHAR(16) NOT NULL,
+			attempts         INT NOT NULL,
+			next_attempt_at  DATETIME(6) NOT NULL,
+			last_error       TEXT NULL,
+			created_at       DATETIME(6) NOT NULL,
+			delivered_at     DATETIME(6) NULL,
+			INDEX outbox_status_aggregate_key (status, aggregate_key, id)
+		)
+	`)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, err := db.Exec(`DROP TABLE IF EXISTS sqx_outbox_test;`)
+		require.NoError(t, err)
+	})
+	return db
+}
+
+func addEvents(t *testing.T, db *sql.DB, events ...outbox.Event) {
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	require.NoError(t, outbox.AddTo(context.Background(), tx, "sqx_outbox_test", events...))
+	require.NoError(t, tx.Commit())
+}
+
+func statuses(t *testing.T, db *sql.DB) []string {
+	statuses, err := sqx.Read[string](context.Background()).
+		WithQueryable(db).
+		Select("status").
+		From("sqx_outbox_test").
+		OrderBy("id").
+		All()
+	require.NoError(t, err)
+	return statuses
+}
+
+func TestRelay(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Only publishes events from committed transactions", func(t *testing.T) {
+		db := setupOutboxTable(t)
+		tx, err := db.Begin()
+		require.NoError(t, err)
+		require.NoError(t, outbox.AddTo(ctx, tx, "sqx_outbox_test", outbox.Event{Key: "u1", Topic: "users", Payload: []byte("{}")}))
+		require.NoError(t, tx.Rollback())
+
+		var published []outbox.Message
+		relay := outbox.NewRelay(db, func(ctx context.Context, msg outbox.Message) error {
+			published = append(published, msg)
+			return nil
+		}, outbox.Options{Table: "sqx_outbox_test"})
+
+		n, err := relay.RelayOnce(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, n)
+
+		addEvents(t, db, outbox.Event{Key: "u1", Topic: "users", Payload: []byte(`{"name":"joe"}`)})
+		n, err = relay.RelayOnce(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+		require.Len(t, published, 1)
+		assert.Equal(t, outbox.Event{Key: "u1", Topic: "users", Payload: []byte(`{"name":"joe"}`)}, published[0].Event)
+		assert.Equal(t, []string{outbox.StatusDelivered}, statuses(t, db))
+	})
+
+	t.Run("Publishes events in order per key", func(t *testing.T) {
+		db := setupOutboxTable(t)
+		addEvents(t, db,
+			outbox.Event{Key: "u1", Topic: "users", Payload: []byte("1")},
+			outbox.Event{Key: "u2", Topic: "users", Payload: []byte("2")},
+			outbox.Event{Key: "u1", Topic: "users", Payload: []byte("3")},
+		)
+
+		var published []string
+		relay := outbox.NewRelay(db, func(ctx context.Context, msg outbox.Message) error {
+			published = append(published, string(msg.Payload))
+			return nil
+		}, outbox.Options{Table: "sqx_outbox_test"})
+
+		n, err := relay.RelayOnce(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+		n, err = relay.RelayOnce(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+		assert.Equal(t, []string{"1", "2", "3"}, published)
+	})
+
+	t.Run("Holds back later events until a failed event is retried", func(t *testing.T) {
+		db := setupOutboxTable(t)
+		addEvents(t, db,
+			outbox.Event{Key: "u1", Topic: "users", Payload: []byte("1")},
+			outbox.Event{Key: "u1", Topic: "users", Payload: []byte("2")},
+		)
+
+		fail := true
+		var published []string
+		relay := outbox.NewRelay(db, func(ctx context.Context, msg outbox.Message) error {
+			if fail {
+				return errors.New("broker unavailable")
+			}
+			published = append(published, string(msg.Payload))
+			return nil
+		}, outbox.Options{Table: "sqx_outbox_test", RetryDelay: func(int) time.Duration { return 50 * time.Millisecond }})
+
+		n, err := relay.RelayOnce(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+		fail = false
+		n, err = relay.RelayOnce(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, n, "the failed event is not due yet, and the next one must wait for it")
+
+		time.Sleep(100 * time.Millisecond)
+		for i := 0; i < 2; i++ {
+			_, err = relay.RelayOnce(ctx)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, []string{"1", "2"}, published)
+	})
+
+	t.Run("Marks events failed after MaxAttempts", func(t *testing.T) {
+		db := setupOutboxTable(t)
+		addEvents(t, db,
+			outbox.Event{Key: "u1", Topic: "users", Payload: []byte("1")},
+			outbox.Event{Key: "u1", Topic: "users", Payload: []byte("2")},
+		)
+
+		var attempts []int
+		relay := outbox.NewRelay(db, func(ctx context.Context, msg outbox.Message) error {
+			if string(msg.Payload) == "1" {
+				attempts = append(attempts, msg.Attempts)
+				return errors.New("bad event")
+			}
+			return nil
+		}, outbox.Options{Table: "sqx_outbox_test", MaxAttempts: 2, RetryDelay: func(int) time.Duration { return 0 }})
+
+		for i := 0; i < 3; i++ {
+			_, err := relay.RelayOnce(ctx)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, []int{0, 1}, attempts)
+		assert.Equal(t, []string{outbox.StatusFailed, outbox.StatusDelivered}, statuses(t, db))
+	})
+
+	t.Run("Run stops when the ctx is done", func(t *testing.T) {
+		db := setupOutboxTable(t)
+		addEvents(t, db, outbox.Event{Key: "u1", Topic: "users", Payload: []byte("1")})
+		published := make(chan outbox.Message, 1)
+		relay := outbox.NewRelay(db, func(ctx context.Context, msg outbox.Message) error {
+			published <- msg
+			return nil
+		}, outbox.Options{Table: "sqx_outbox_test", PollInterval: 10 * time.Millisecond})
+
+		ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, relay.Run(ctx), context.DeadlineExceeded)
+		assert.Len(t, published, 1)
+	})
+}
@@ -22,3 +22,23 @@ type Queryable interface {
 	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
 	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
 }
+
+// Wrapper is implemented by Queryables that decorate another Queryable, such as sqxtest.InjectFaults. sqx unwraps them
+// to find out whether statements run inside a transaction, which is never retried and is required for locking reads.
+type Wrapper interface {
+	Unwrap() Queryable
+}
+
+// inTx reports whether queryable is a *sql.Tx, or a Wrapper around one.
+func inTx(queryable Queryable) bool {
+	for {
+		switch q := queryable.(type) {
+		case *sql.Tx:
+			return true
+		case Wrapper:
+			queryable = q.Unwrap()
+		default:
+			return false
+		}
+	}
+}
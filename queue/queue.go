@@ -0,0 +1,321 @@
+// Package queue provides a work queue stored in a database table, built on sqx. Several workers can dequeue from the
+// same table concurrently - each message is claimed by a single worker with SELECT ... FOR UPDATE SKIP LOCKED.
+//
+// The table must have the following columns:
+//
+//	CREATE TABLE jobs (
+//		id          BIGINT AUTO_INCREMENT PRIMARY KEY,
+//		payload     BLOB NOT NULL,
+//		status      VARCHAR(16) NOT NULL,
+//		attempts    INT NOT NULL,
+//		visible_at  DATETIME(6) NOT NULL,
+//		receipt     VARCHAR(36) NULL,
+//		last_error  TEXT NULL,
+//		created_at  DATETIME(6) NOT NULL,
+//		INDEX jobs_status_visible_at (status, visible_at)
+//	);
+//
+// Payloads are stored as JSON.
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/stytchauth/sqx"
+	"github.com/stytchauth/sqx/internal/schedule"
+)
+
+// ErrClaimExpired is returned by Ack and Nack when the message's visibility timeout ran out before it was acknowledged,
+// so it may already have been claimed by another worker.
+var ErrClaimExpired = errors.New("queue: claim expired")
+
+const (
+	// StatusReady is the status of messages which can be dequeued, once they are visible.
+	StatusReady = "ready"
+	// StatusDead is the status of messages which were dead-lettered after running out of attempts.
+	StatusDead = "dead"
+)
+
+const (
+	// DefaultVisibilityTimeout is how long a dequeued message stays claimed, unless Options.VisibilityTimeout is set.
+	DefaultVisibilityTimeout = 30 * time.Second
+	// DefaultMaxAttempts is how many times a message is dequeued before it is dead-lettered, unless
+	// Options.MaxAttempts is set.
+	DefaultMaxAttempts = 5
+)
+
+// Options configure a Queue.
+type Options struct {
+	// VisibilityTimeout is how long a dequeued message stays claimed by a worker. If it is neither acked nor nacked in
+	// that time, it becomes visible to other workers again.
+	VisibilityTimeout time.Duration
+	// MaxAttempts is how many times a message is dequeued before a Nack dead-letters it.
+	MaxAttempts int
+	// RetryDelay returns how long a nacked message waits before it becomes visible again, given the number of times it
+	// has been dequeued. If nil, DefaultRetryDelay is used.
+	RetryDelay func(attempts int) time.Duration
+}
+
+// DefaultRetryDelay waits one second after the first attempt, and doubles the wait after each further attempt, up to
+// an hour.
+func DefaultRetryDelay(attempts int) time.Duration {
+	return schedule.Backoff(attempts, time.Second, time.Hour)
+}
+
+// Queue is a work queue of payloads of type T, stored in a table.
+type Queue[T any] struct {
+	db    *sql.DB
+	table string
+	opts  Options
+}
+
+// New creates a Queue over table. The db is used to open the transactions that messages are claimed in.
+func New[T any](db *sql.DB, table string, opts Options) *Queue[T] {
+	if opts.VisibilityTimeout <= 0 {
+		opts.VisibilityTimeout = DefaultVisibilityTimeout
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.RetryDelay == nil {
+		opts.RetryDelay = DefaultRetryDelay
+	}
+	return &Queue[T]{db: db, table: table, opts: opts}
+}
+
+// Message is a message claimed by Dequeue. Pass it to Ack once it has been handled, or to Nack if handling failed.
+type Message[T any] struct {
+	ID      int64
+	Payload T
+	// Attempts is the number of times the message has been dequeued, including this time.
+	Attempts int
+	// LastError is the error passed to the last Nack, if any.
+	LastError *string
+	receipt   string
+}
+
+// row is a message as stored in the queue table.
+type row struct {
+	ID        int64     `db:"id"`
+	Payload   []byte    `db:"payload"`
+	Status    string    `db:"status"`
+	Attempts  int       `db:"attempts"`
+	VisibleAt time.Time `db:"visible_at"`
+	Receipt   *string   `db:"receipt"`
+	LastError *string   `db:"last_error"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// Enqueue adds payloads to the queue. They can be dequeued immediately.
+func (q *Queue[T]) Enqueue(ctx context.Context, payloads ...T) error {
+	return q.EnqueueAt(ctx, schedule.Now(), payloads...)
+}
+
+// EnqueueAt adds payloads to the queue which cannot be dequeued until visibleAt.
+func (q *Queue[T]) EnqueueAt(ctx context.Context, visibleAt time.Time, payloads ...T) error {
+	if len(payloads) == 0 {
+		return nil
+	}
+	created := schedule.Now()
+	rows := make([]row, len(payloads))
+	for i, payload := range payloads {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("queue: could not encode payload: %w", err)
+		}
+		rows[i] = row{Payload: data, Status: StatusReady, VisibleAt: visibleAt.UTC(), CreatedAt: created}
+	}
+	return sqx.TypedWrite[row](ctx).
+		WithQueryable(q.db).
+		InsertMany(q.table).
+		FromItems(rows, "id").
+		Do()
+}
+
+// Dequeue claims up to n visible messages, oldest first, and hides them from other workers for the visibility timeout.
+// It returns no messages if none are visible.
+//
+// Candidate messages are selected with FOR UPDATE SKIP LOCKED, and then claimed with an UPDATE which re-checks that
+// each one is still visible, so a message is never claimed twice even if another worker read it at the same time. Only
+// the messages which this call claimed are returned, so it may return fewer than n even if more are visible.
+//
+// Messages whose payload cannot be decoded are dead-lettered instead of being returned.
+func (q *Queue[T]) Dequeue(ctx context.Context, n int) ([]Message[T], error) {
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	claimedAt := schedule.Now()
+	ids, err := sqx.Read[int64](ctx).
+		WithQueryable(tx).
+		Select("id").
+		From(q.table).
+		Where(sqx.Eq{"status": StatusReady}).
+		Where(sqx.LtOrEq{"visible_at": claimedAt}).
+		OrderBy("visible_at", "id").
+		Limit(uint64(n)).
+		ForUpdate().
+		SkipLocked().
+		All()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	receipt := uuid.NewString()
+	err = sqx.Write(ctx).
+		WithQueryable(tx).
+		Update(q.table).
+		Set("attempts", sqx.Expr("attempts + 1")).
+		Set("visible_at", claimedAt.Add(q.opts.VisibilityTimeout)).
+		Set("receipt", receipt).
+		Where(sqx.Eq{"id": ids, "status": StatusReady}).
+		Where(sqx.LtOrEq{"visible_at": claimedAt}).
+		Do()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := sqx.Read[row](ctx).
+		WithQueryable(tx).
+		Select("*").
+		From(q.table).
+		Where(sqx.Eq{"receipt": receipt}).
+		OrderBy("id").
+		All()
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]Message[T], 0, len(rows))
+	for _, r := range rows {
+		var payload T
+		if err := json.Unmarshal(r.Payload, &payload); err != nil {
+			if err := q.deadLetter(ctx, tx, r.ID, fmt.Sprintf("could not decode payload: %s", err)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		messages = append(messages, Message[T]{
+			ID:        r.ID,
+			Payload:   payload,
+			Attempts:  r.Attempts,
+			LastError: r.LastError,
+			receipt:   receipt,
+		})
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// Ack removes a handled message from the queue. It returns ErrClaimExpired if the message's visibility timeout has run
+// out.
+func (q *Queue[T]) Ack(ctx context.Context, msg Message[T]) error {
+	res, err := sqx.Write(ctx).
+		WithQueryable(q.db).
+		Delete(q.table).
+		Where(sqx.Eq{"id": msg.ID, "receipt": msg.receipt}).
+		DoResult()
+	return claimResult(res, err)
+}
+
+// Nack returns a message whose handling failed with cause to the queue, to be retried after the RetryDelay. If the
+// message has been dequeued MaxAttempts times, it is dead-lettered instead. It returns ErrClaimExpired if the message's
+// visibility timeout has run out.
+func (q *Queue[T]) Nack(ctx context.Context, msg Message[T], cause error) error {
+	lastError := cause.Error()
+	if msg.Attempts >= q.opts.MaxAttempts {
+		res, err := sqx.Write(ctx).
+			WithQueryable(q.db).
+			Update(q.table).
+			Set("status", StatusDead).
+			Set("receipt", nil).
+			Set("last_error", lastError).
+			Where(sqx.Eq{"id": msg.ID, "receipt": msg.receipt}).
+			DoResult()
+		return claimResult(res, err)
+	}
+
+	res, err := sqx.Write(ctx).
+		WithQueryable(q.db).
+		Update(q.table).
+		Set("visible_at", schedule.Now().Add(q.opts.RetryDelay(msg.Attempts))).
+		Set("receipt", nil).
+		Set("last_error", lastError).
+		Where(sqx.Eq{"id": msg.ID, "receipt": msg.receipt}).
+		DoResult()
+	return claimResult(res, err)
+}
+
+// DeadLetters returns up to limit dead-lettered messages, oldest first.
+func (q *Queue[T]) DeadLetters(ctx context.Context, limit int) ([]Message[T], error) {
+	rows, err := sqx.Read[row](ctx).
+		WithQueryable(q.db).
+		Select("*").
+		From(q.table).
+		Where(sqx.Eq{"status": StatusDead}).
+		OrderBy("id").
+		Limit(uint64(limit)).
+		All()
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]Message[T], len(rows))
+	for i, r := range rows {
+		messages[i] = Message[T]{ID: r.ID, Attempts: r.Attempts, LastError: r.LastError}
+		// Dead letters may be there because their payload could not be decoded, so decoding errors are ignored.
+		_ = json.Unmarshal(r.Payload, &messages[i].Payload)
+	}
+	return messages, nil
+}
+
+// Redrive returns dead-lettered messages to the queue with their attempts reset, e.g. after fixing the bug that made
+// them fail.
+func (q *Queue[T]) Redrive(ctx context.Context, ids ...int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return sqx.Write(ctx).
+		WithQueryable(q.db).
+		Update(q.table).
+		Set("status", StatusReady).
+		Set("attempts", 0).
+		Set("visible_at", schedule.Now()).
+		Where(sqx.Eq{"id": ids, "status": StatusDead}).
+		Do()
+}
+
+func (q *Queue[T]) deadLetter(ctx context.Context, tx sqx.Queryable, id int64, reason string) error {
+	return sqx.Write(ctx).
+		WithQueryable(tx).
+		Update(q.table).
+		Set("status", StatusDead).
+		Set("receipt", nil).
+		Set("last_error", reason).
+		Where(sqx.Eq{"id": id}).
+		Do()
+}
+
+// claimResult returns ErrClaimExpired if a statement guarded by a message's receipt did not match the message.
+func claimResult(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrClaimExpired
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package queue_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stytchauth/sqx/queue"
+)
+
+type job struct {
+	Name string `json:"name"`
+}
+
+// setupJobsTable opens a connection to the test database and creates an empty queue table.
+func setupJobsTable(t *testing.T) *sql.DB {
+	db, err := sql.Open("mysql", "sqx:sqx@tcp(localhost:4306)/sqx?parseTime=true")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Logf("Close DB connection: %s", err.Error())
+		}
+	})
+
+	_, err = db.Exec(`DROP TABLE IF EXISTS sqx_queue_test;`)
+	require.NoError(t, err, "Did you remember to run make services?")
+	_, err = db.Exec(`
+		CREATE TABLE sqx_queue_test (
+			id          BIGINT AUTO_INCREMENT PRIMARY KEY,
+			payload     BLOB NOT NULL,
+			status      VARCHAR(16) NOT NULL,
+			attempts    INT NOT NULL,
+			visible_at  DATETIME(6) NOT NULL,
+			receipt     VARCHAR(36) NULL,
+			last_error  TEXT NULL,
+			created_at  DATETIME(6) NOT NULL,
+			INDEX jobs_status_visible_at (status, visible_at)
+		)
+	`)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, err := db.Exec(`DROP TABLE IF EXISTS sqx_queue_test;`)
+		require.NoError(t, err)
+	})
+	return db
+}
+
+func TestQueue(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Dequeues messages in order and removes them on Ack", func(t *testing.T) {
+		q := queue.New[job](setupJobsTable(t), "sqx_queue_test", queue.Options{})
+		require.NoError(t, q.Enqueue(ctx, job{"a"}, job{"b"}, job{"c"}))
+
+		msgs, err := q.Dequeue(ctx, 2)
+		require.NoError(t, err)
+		require.Len(t, msgs, 2)
+		assert.Equal(t, job{"a"}, msgs[0].Payload)
+		assert.Equal(t, job{"b"}, msgs[1].Payload)
+		assert.Equal(t, 1, msgs[0].Attempts)
+
+		more, err := q.Dequeue(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, more, 1, "claimed messages should be hidden")
+		assert.Equal(t, job{"c"}, more[0].Payload)
+
+		for _, msg := range append(msgs, more...) {
+			require.NoError(t, q.Ack(ctx, msg))
+		}
+		assert.ErrorIs(t, q.Ack(ctx, msgs[0]), queue.ErrClaimExpired)
+
+		empty, err := q.Dequeue(ctx, 10)
+		require.NoError(t, err)
+		assert.Empty(t, empty)
+	})
+
+	t.Run("Redelivers messages after the visibility timeout", func(t *testing.T) {
+		q := queue.New[job](setupJobsTable(t), "sqx_queue_test", queue.Options{VisibilityTimeout: 50 * time.Millisecond})
+		require.NoError(t, q.Enqueue(ctx, job{"a"}))
+
+		first, err := q.Dequeue(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, first, 1)
+		time.Sleep(100 * time.Millisecond)
+
+		second, err := q.Dequeue(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, second, 1)
+		assert.Equal(t, 2, second[0].Attempts)
+		assert.ErrorIs(t, q.Ack(ctx, first[0]), queue.ErrClaimExpired)
+		assert.NoError(t, q.Ack(ctx, second[0]))
+	})
+
+	t.Run("Retries nacked messages and dead-letters them after MaxAttempts", func(t *testing.T) {
+		q := queue.New[job](setupJobsTable(t), "sqx_queue_test", queue.Options{
+			MaxAttempts: 2,
+			RetryDelay:  func(int) time.Duration { return 0 },
+		})
+		require.NoError(t, q.Enqueue(ctx, job{"a"}))
+
+		for attempt := 1; attempt <= 2; attempt++ {
+			msgs, err := q.Dequeue(ctx, 1)
+			require.NoError(t, err)
+			require.Len(t, msgs, 1)
+			assert.Equal(t, attempt, msgs[0].Attempts)
+			require.NoError(t, q.Nack(ctx, msgs[0], errors.New("boom")))
+		}
+
+		msgs, err := q.Dequeue(ctx, 1)
+		require.NoError(t, err)
+		assert.Empty(t, msgs)
+
+		dead, err := q.DeadLetters(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, dead, 1)
+		assert.Equal(t, job{"a"}, dead[0].Payload)
+		require.NotNil(t, dead[0].LastError)
+		assert.Equal(t, "boom", *dead[0].LastError)
+
+		require.NoError(t, q.Redrive(ctx, dead[0].ID))
+		msgs, err = q.Dequeue(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, msgs, 1)
+		assert.Equal(t, 1, msgs[0].Attempts)
+	})
+
+	t.Run("Delays nacked messages", func(t *testing.T) {
+		q := queue.New[job](setupJobsTable(t), "sqx_queue_test", queue.Options{})
+		require.NoError(t, q.Enqueue(ctx, job{"a"}))
+
+		msgs, err := q.Dequeue(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, msgs, 1)
+		require.NoError(t, q.Nack(ctx, msgs[0], errors.New("boom")))
+
+		msgs, err = q.Dequeue(ctx, 1)
+		require.NoError(t, err)
+		assert.Empty(t, msgs)
+	})
+
+	t.Run("Dead-letters payloads that cannot be decoded", func(t *testing.T) {
+		db := setupJobsTable(t)
+		require.NoError(t, queue.New[string](db, "sqx_queue_test", queue.Options{}).Enqueue(ctx, "not a job"))
+		q := queue.New[job](db, "sqx_queue_test", queue.Options{})
+
+		msgs, err := q.Dequeue(ctx, 1)
+		require.NoError(t, err)
+		assert.Empty(t, msgs)
+
+		dead, err := q.DeadLetters(ctx, 10)
+		require.NoError(t, err)
+		assert.Len(t, dead, 1)
+	})
+
+	t.Run("Hands each message to a single worker", func(t *testing.T) {
+		q := queue.New[job](setupJobsTable(t), "sqx_queue_test", queue.Options{})
+		jobs := make([]job, 20)
+		require.NoError(t, q.Enqueue(ctx, jobs...))
+
+		var mu sync.Mutex
+		var claimed []int64
+		errs := make([]error, 4)
+		var wg sync.WaitGroup
+		for w := range errs {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for {
+					msgs, err := q.Dequeue(ctx, 3)
+					if err != nil || len(msgs) == 0 {
+						errs[w] = err
+						return
+					}
+					mu.Lock()
+					for _, msg := range msgs {
+						claimed = append(claimed, msg.ID)
+					}
+					mu.Unlock()
+				}
+			}(w)
+		}
+		wg.Wait()
+		for _, err := range errs {
+			require.NoError(t, err)
+		}
+
+		seen := map[int64]bool{}
+		for _, id := range claimed {
+			assert.False(t, seen[id], "message %d was claimed twice", id)
+			seen[id] = true
+		}
+		assert.Len(t, seen, len(jobs))
+	})
+}
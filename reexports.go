@@ -40,3 +40,8 @@ type ILike = sq.ILike
 
 // NotILike represents a SQL NOT ILIKE expression. It is a re-export of squirrel.NotILike.
 type NotILike = sq.NotILike
+
+// Expr builds a SQL fragment with placeholders, e.g. Expr("attempts + ?", 1). It is a re-export of squirrel.Expr.
+func Expr(sql string, args ...interface{}) Sqlizer {
+	return sq.Expr(sql, args...)
+}
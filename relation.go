@@ -0,0 +1,236 @@
+package sqx
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/stytchauth/sqx/internal/dbtag"
+)
+
+// Relation loads rows related to the results of a SelectBuilder[T]. Create one with HasMany, HasOne or BelongsTo and
+// pass it to SelectBuilder.With.
+type Relation[T any] interface {
+	// load loads the related rows of items and stores them in items, using the queryable, logger and ctx of rc.
+	load(rc runCtx, timeout time.Duration, items []T) error
+}
+
+// Rel is a relation between rows of T and rows of R, matched by a column of each.
+type Rel[T, R any] struct {
+	local    string
+	remote   string
+	many     bool
+	table    string
+	name     string
+	where    []Sqlizer
+	orderBy  []string
+	children []Relation[R]
+}
+
+// HasMany declares that each T has any number of Rs, whose foreignKey column holds the value of T's key column. The
+// Rs are stored in the []R or []*R field of T tagged `sqx:"rel=<name>"`, where name defaults to the table the Rs are
+// read from.
+//
+//	type User struct {
+//		ID   string `db:"id"`
+//		Pets []Pet  `sqx:"rel=pets"`
+//	}
+//
+//	users, err := sqx.Read[User](ctx).
+//		Select("*").
+//		From("users").
+//		With(sqx.HasMany[User, Pet]("id", "user_id").From("pets")).
+//		All()
+func HasMany[T, R any](key, foreignKey string) Rel[T, R] {
+	return Rel[T, R]{local: key, remote: foreignKey, many: true}
+}
+
+// HasOne declares that each T has at most one R, whose foreignKey column holds the value of T's key column. The R is
+// stored in the *R or R field of T tagged `sqx:"rel=<name>"`. If several Rs match, the first one is used.
+func HasOne[T, R any](key, foreignKey string) Rel[T, R] {
+	return Rel[T, R]{local: key, remote: foreignKey}
+}
+
+// BelongsTo declares that each T refers to at most one R, whose key column holds the value of T's foreignKey column.
+// The R is stored in the *R or R field of T tagged `sqx:"rel=<name>"`.
+func BelongsTo[T, R any](foreignKey, key string) Rel[T, R] {
+	return Rel[T, R]{local: foreignKey, remote: key}
+}
+
+// From sets the table that related rows are read from. It also names the relation, unless As is used.
+func (r Rel[T, R]) From(table string) Rel[T, R] {
+	r.table = table
+	return r
+}
+
+// As sets the name of the relation, which is the rel option in the sqx tag of the field the rows are stored in.
+func (r Rel[T, R]) As(name string) Rel[T, R] {
+	r.name = name
+	return r
+}
+
+// Where filters the related rows, e.g. to skip inactive ones.
+func (r Rel[T, R]) Where(pred Sqlizer) Rel[T, R] {
+	r.where = append(append([]Sqlizer{}, r.where...), pred)
+	return r
+}
+
+// OrderBy sets the order of the related rows in a HasMany relation.
+func (r Rel[T, R]) OrderBy(orderBys ...string) Rel[T, R] {
+	r.orderBy = append(append([]string{}, r.orderBy...), orderBys...)
+	return r
+}
+
+// With loads relations of the related rows as well, e.g. the toys of each user's pets.
+func (r Rel[T, R]) With(relations ...Relation[R]) Rel[T, R] {
+	r.children = append(append([]Relation[R]{}, r.children...), relations...)
+	return r
+}
+
+func (r Rel[T, R]) load(rc runCtx, timeout time.Duration, items []T) error {
+	name := r.name
+	if name == "" {
+		name = r.table
+	}
+	if r.table == "" {
+		return fmt.Errorf("relation %q: no table - call From to set it", name)
+	}
+
+	itemType := reflect.TypeOf((*T)(nil)).Elem()
+	relatedType := reflect.TypeOf((*R)(nil)).Elem()
+	if itemType.Kind() != reflect.Struct || relatedType.Kind() != reflect.Struct {
+		return fmt.Errorf("relation %q: relations can only be loaded between structs", name)
+	}
+	dest, ok := dbtag.RelationField(itemType, name)
+	if !ok {
+		return fmt.Errorf("relation %q: %s has no field tagged `%s:\"rel=%s\"`", name, itemType, dbtag.RelationName, name)
+	}
+	destType := itemType.FieldByIndex(dest.Index).Type
+	if err := r.checkDestType(destType, relatedType); err != nil {
+		return fmt.Errorf("relation %q: %w", name, err)
+	}
+	local, ok := dbtag.ScanFields(itemType)[r.local]
+	if !ok {
+		return fmt.Errorf("relation %q: %s has no field for column %q", name, itemType, r.local)
+	}
+	remote, ok := dbtag.ScanFields(relatedType)[r.remote]
+	if !ok {
+		return fmt.Errorf("relation %q: %s has no field for column %q", name, relatedType, r.remote)
+	}
+
+	var keys []any
+	seen := map[any]bool{}
+	for i := range items {
+		key, ok := relationKey(reflect.ValueOf(&items[i]).Elem().FieldByIndex(local.Index))
+		if ok && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	var related []R
+	if len(keys) > 0 {
+		builder := typedRunCtx[R]{rc}.
+			Select("*").
+			From(r.table).
+			Where(Eq{r.remote: keys}).
+			Timeout(timeout).
+			With(r.children...)
+		for _, pred := range r.where {
+			builder = builder.Where(pred)
+		}
+		if len(r.orderBy) > 0 {
+			builder = builder.OrderBy(r.orderBy...)
+		}
+		var err error
+		if related, err = builder.All(); err != nil {
+			return fmt.Errorf("relation %q: %w", name, err)
+		}
+	}
+
+	byKey := map[any][]reflect.Value{}
+	for i := range related {
+		value := reflect.ValueOf(&related[i])
+		if key, ok := relationKey(value.Elem().FieldByIndex(remote.Index)); ok {
+			byKey[key] = append(byKey[key], value)
+		}
+	}
+	for i := range items {
+		item := reflect.ValueOf(&items[i]).Elem()
+		key, _ := relationKey(item.FieldByIndex(local.Index))
+		storeRelated(item.FieldByIndex(dest.Index), byKey[key], r.many)
+	}
+	return nil
+}
+
+// checkDestType returns an error if related rows cannot be stored in a field of type t.
+func (r Rel[T, R]) checkDestType(t, relatedType reflect.Type) error {
+	if r.many {
+		if t.Kind() == reflect.Slice && (t.Elem() == relatedType || t.Elem() == reflect.PtrTo(relatedType)) {
+			return nil
+		}
+		return fmt.Errorf("field must be a []%s or []*%s, not %s", relatedType, relatedType, t)
+	}
+	if t == relatedType || t == reflect.PtrTo(relatedType) {
+		return nil
+	}
+	return fmt.Errorf("field must be a %s or *%s, not %s", relatedType, relatedType, t)
+}
+
+// relationKey returns the value of a key field in a form that can be compared with the keys of other types, e.g. an
+// int key and an int64 foreign key. It returns false if the key is NULL.
+func relationKey(field reflect.Value) (any, bool) {
+	key, err := driver.DefaultParameterConverter.ConvertValue(field.Interface())
+	if err != nil || key == nil {
+		return nil, false
+	}
+	if b, ok := key.([]byte); ok {
+		return string(b), true
+	}
+	return key, true
+}
+
+// storeRelated stores pointers to related rows in dest, a slice or a single value or pointer.
+func storeRelated(dest reflect.Value, related []reflect.Value, many bool) {
+	if !many {
+		if len(related) == 0 {
+			dest.Set(reflect.Zero(dest.Type()))
+		} else if dest.Kind() == reflect.Ptr {
+			dest.Set(related[0])
+		} else {
+			dest.Set(related[0].Elem())
+		}
+		return
+	}
+	slice := reflect.MakeSlice(dest.Type(), 0, len(related))
+	for _, value := range related {
+		if dest.Type().Elem().Kind() == reflect.Ptr {
+			slice = reflect.Append(slice, value)
+		} else {
+			slice = reflect.Append(slice, value.Elem())
+		}
+	}
+	dest.Set(slice)
+}
+
+// With loads the given relations after the query has run, with one extra query per relation, and stores the related
+// rows in the fields of T tagged `sqx:"rel=<name>"`. It applies to All, One, OneStrict and First.
+func (b SelectBuilder[T]) With(relations ...Relation[T]) SelectBuilder[T] {
+	b.relations = append(append([]Relation[T]{}, b.relations...), relations...)
+	return b
+}
+
+// loadRelations loads the relations set with With into items.
+func (b SelectBuilder[T]) loadRelations(items []T) error {
+	if len(items) == 0 {
+		return nil
+	}
+	rc := runCtx{ctx: b.ctx, queryable: b.queryable, logger: b.logger, retry: b.retry}
+	for _, relation := range b.relations {
+		if err := relation.load(rc, b.timeout, items); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package sqx_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stytchauth/sqx"
+	"github.com/stytchauth/sqx/sqxtest"
+)
+
+type relUser struct {
+	ID      string      `db:"id"`
+	Name    string      `db:"name"`
+	Pets    []relPet    `sqx:"rel=pets"`
+	Profile *relProfile `sqx:"rel=profile"`
+}
+
+type relPet struct {
+	ID     int64     `db:"id"`
+	UserID *string   `db:"user_id"`
+	Name   string    `db:"name"`
+	Owner  *relUser  `sqx:"rel=owner"`
+	Toys   []*relToy `sqx:"rel=toys"`
+}
+
+type relToy struct {
+	PetID int    `db:"pet_id"`
+	Name  string `db:"name"`
+}
+
+type relProfile struct {
+	UserID string `db:"user_id"`
+	Bio    string `db:"bio"`
+}
+
+func TestRelations(t *testing.T) {
+	ctx := context.Background()
+	u1 := relUser{ID: "u1", Name: "alice"}
+	u2 := relUser{ID: "u2", Name: "bob"}
+	rex := relPet{ID: 1, UserID: sqx.Ptr("u1"), Name: "rex"}
+	tom := relPet{ID: 2, UserID: sqx.Ptr("u1"), Name: "tom"}
+	stray := relPet{ID: 3, Name: "stray"}
+
+	t.Run("Loads has-many and has-one relations", func(t *testing.T) {
+		fake := sqxtest.New()
+		fake.ExpectQuery("SELECT * FROM users").WillReturnRows(sqxtest.RowsFromItems(u1, u2))
+		fake.ExpectQuery("SELECT * FROM pets WHERE user_id IN (?,?) ORDER BY name").
+			WithArgs("u1", "u2").
+			WillReturnRows(sqxtest.RowsFromItems(rex, tom))
+		fake.ExpectQuery("SELECT * FROM user_profiles WHERE user_id IN (?,?)").
+			WithArgs("u1", "u2").
+			WillReturnRows(sqxtest.RowsFromItems(relProfile{UserID: "u2", Bio: "hi"}))
+
+		users, err := sqx.Read[relUser](ctx).
+			WithQueryable(fake).
+			Select("*").
+			From("users").
+			With(
+				sqx.HasMany[relUser, relPet]("id", "user_id").From("pets").OrderBy("name"),
+				sqx.HasOne[relUser, relProfile]("id", "user_id").From("user_profiles").As("profile"),
+			).
+			All()
+		require.NoError(t, err)
+		require.Len(t, users, 2)
+		assert.Equal(t, []relPet{rex, tom}, users[0].Pets)
+		assert.Nil(t, users[0].Profile)
+		assert.Equal(t, []relPet{}, users[1].Pets)
+		assert.Equal(t, &relProfile{UserID: "u2", Bio: "hi"}, users[1].Profile)
+		fake.AssertExpectations(t)
+	})
+
+	t.Run("Loads belongs-to relations and filters related rows", func(t *testing.T) {
+		fake := sqxtest.New()
+		fake.ExpectQuery("SELECT * FROM pets WHERE id = ?").WillReturnRows(sqxtest.RowsFromItems(rex, stray))
+		fake.ExpectQuery("SELECT * FROM users WHERE id IN (?)").WithArgs("u1").WillReturnRows(sqxtest.RowsFromItems(u1))
+		fake.ExpectQuery("SELECT * FROM toys WHERE pet_id IN (?,?) AND name <> ?").
+			WithArgs(1, 3, "broken").
+			WillReturnRows(sqxtest.RowsFromItems(relToy{PetID: 3, Name: "ball"}))
+
+		pet, err := sqx.Read[relPet](ctx).
+			WithQueryable(fake).
+			Select("*").
+			From("pets").
+			Where(sqx.Eq{"id": 1}).
+			With(
+				sqx.BelongsTo[relPet, relUser]("user_id", "id").From("users").As("owner"),
+				sqx.HasMany[relPet, relToy]("id", "pet_id").From("toys").Where(sqx.NotEq{"name": "broken"}),
+			).
+			First()
+		require.NoError(t, err)
+		assert.Equal(t, &u1, pet.Owner)
+		assert.Empty(t, pet.Toys)
+		fake.AssertExpectations(t)
+	})
+
+	t.Run("Loads relations of related rows", func(t *testing.T) {
+		fake := sqxtest.New()
+		fake.ExpectQuery("SELECT * FROM users").WillReturnRows(sqxtest.RowsFromItems(u1))
+		fake.ExpectQuery("SELECT * FROM pets WHERE user_id IN (?)").WillReturnRows(sqxtest.RowsFromItems(rex, tom))
+		fake.ExpectQuery("SELECT * FROM toys WHERE pet_id IN (?,?)").
+			WithArgs(1, 2).
+			WillReturnRows(sqxtest.RowsFromItems(relToy{PetID: 2, Name: "ball"}, relToy{PetID: 2, Name: "bone"}))
+
+		users, err := sqx.Read[relUser](ctx).
+			WithQueryable(fake).
+			Select("*").
+			From("users").
+			With(sqx.HasMany[relUser, relPet]("id", "user_id").
+				From("pets").
+				With(sqx.HasMany[relPet, relToy]("id", "pet_id").From("toys"))).
+			All()
+		require.NoError(t, err)
+		require.Len(t, users[0].Pets, 2)
+		assert.Empty(t, users[0].Pets[0].Toys)
+		assert.Equal(t, []*relToy{{PetID: 2, Name: "ball"}, {PetID: 2, Name: "bone"}}, users[0].Pets[1].Toys)
+		fake.AssertExpectations(t)
+	})
+
+	t.Run("Skips the relation query when there are no keys", func(t *testing.T) {
+		fake := sqxtest.New()
+		fake.ExpectQuery("SELECT * FROM pets").WillReturnRows(sqxtest.RowsFromItems(stray))
+
+		pets, err := sqx.Read[relPet](ctx).
+			WithQueryable(fake).
+			Select("*").
+			From("pets").
+			With(sqx.BelongsTo[relPet, relUser]("user_id", "id").From("users").As("owner")).
+			All()
+		require.NoError(t, err)
+		assert.Nil(t, pets[0].Owner)
+		fake.AssertExpectations(t)
+	})
+
+	t.Run("Reports relations that cannot be stored", func(t *testing.T) {
+		fake := sqxtest.New()
+		fake.ExpectQuery("SELECT * FROM users").WillReturnRows(sqxtest.RowsFromItems(u1))
+
+		_, err := sqx.Read[relUser](ctx).
+			WithQueryable(fake).
+			Select("*").
+			From("users").
+			With(sqx.HasMany[relUser, relPet]("id", "user_id").From("animals")).
+			All()
+		assert.ErrorContains(t, err, `has no field tagged `+"`"+`sqx:"rel=animals"`)
+
+		fake.ExpectQuery("SELECT * FROM users").WillReturnRows(sqxtest.RowsFromItems(u1))
+		_, err = sqx.Read[relUser](ctx).
+			WithQueryable(fake).
+			Select("*").
+			From("users").
+			With(sqx.HasMany[relUser, relToy]("id", "pet_id").From("toys").As("pets")).
+			All()
+		assert.ErrorContains(t, err, "field must be a []sqx_test.relToy")
+	})
+
+	t.Run("Relation fields are not columns", func(t *testing.T) {
+		setMap, err := sqx.ToSetMap(&u1)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"id": "u1", "name": "alice"}, setMap)
+	})
+}
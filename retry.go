@@ -0,0 +1,107 @@
+package sqx
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/rand"
+	"syscall"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/stytchauth/sqx/internal/schedule"
+)
+
+// RetryPolicy configures how statements are retried after transient errors, such as a broken connection or a deadlock.
+// SELECTs are retried whenever a policy is set, but writes are only retried if they are marked with Idempotent, since a
+// write which failed with a broken connection may have been applied anyway.
+//
+// Statements are never retried when the Queryable is a *sql.Tx, because a failed statement aborts the transaction on
+// most databases - retry the whole transaction instead.
+//
+// The zero RetryPolicy does not retry.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times a statement is run, including the first attempt.
+	MaxAttempts int
+	// Backoff is the delay before the first retry. The delay doubles after each retry.
+	Backoff time.Duration
+	// MaxBackoff caps the delay between retries. Zero means no cap.
+	MaxBackoff time.Duration
+	// Jitter randomizes each delay by up to this fraction of it, between 0 and 1, so that clients which failed together
+	// do not retry together.
+	Jitter float64
+	// Retryable reports whether an error is transient. If nil, IsRetryable is used.
+	Retryable func(error) bool
+}
+
+var defaultRetryPolicy RetryPolicy
+
+// SetDefaultRetryPolicy sets the RetryPolicy used by builders which do not have one set with WithRetryPolicy.
+func SetDefaultRetryPolicy(policy RetryPolicy) {
+	defaultRetryPolicy = policy
+}
+
+// IsRetryable reports whether err is a transient error that a statement can be retried after: a broken or reset
+// connection, or a MySQL deadlock. Context errors are never retryable.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlDeadlock
+}
+
+// mysqlDeadlock is ER_LOCK_DEADLOCK, returned when a transaction is rolled back to resolve a deadlock.
+const mysqlDeadlock = 1213
+
+func (p RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryable(err)
+}
+
+// delay returns how long to wait before the given retry, starting from 1.
+func (p RetryPolicy) delay(retry int) time.Duration {
+	delay := schedule.Backoff(retry, p.Backoff, p.MaxBackoff)
+	if p.Jitter > 0 {
+		delay += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(delay))
+	}
+	return delay
+}
+
+// withRetries runs fn until it succeeds, fails with an error that is not retryable, or has been attempted
+// policy.MaxAttempts times. fn is only attempted once if enabled is false or queryable is a transaction, see inTx. The last error is
+// returned if ctx is done while waiting to retry.
+func withRetries[R any](
+	ctx context.Context, policy RetryPolicy, enabled bool, queryable Queryable, logger Logger, fn func() (R, error),
+) (R, error) {
+	attempts := policy.MaxAttempts
+	if !enabled || inTx(queryable) || attempts < 1 {
+		attempts = 1
+	}
+	for attempt := 1; ; attempt++ {
+		res, err := fn()
+		if err == nil || attempt >= attempts || !policy.retryable(err) {
+			return res, err
+		}
+		if logger != nil {
+			logger.Printf("[WARN] sqx: attempt %d of %d failed, retrying: %s", attempt, attempts, err)
+		}
+		timer := time.NewTimer(policy.delay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return res, err
+		case <-timer.C:
+		}
+	}
+}
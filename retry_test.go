@@ -0,0 +1,128 @@
+package sqx_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stytchauth/sqx"
+	"github.com/stytchauth/sqx/sqxtest"
+)
+
+func TestRetry(t *testing.T) {
+	ctx := context.Background()
+	policy := sqx.RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond, Jitter: 0.5}
+
+	t.Run("Retries selects", func(t *testing.T) {
+		fake := sqxtest.New()
+		db := sqxtest.InjectFaults(fake)
+		fault := db.Always().Times(2).WillReturnError(sqxtest.ErrDeadlock)
+
+		_, err := sqx.Read[Widget](ctx).WithQueryable(db).WithRetryPolicy(policy).Select("*").From("widgets").All()
+		require.NoError(t, err)
+		assert.Equal(t, 2, fault.Injected())
+		assert.Len(t, fake.Calls(), 1)
+	})
+
+	t.Run("Gives up after MaxAttempts", func(t *testing.T) {
+		db := sqxtest.InjectFaults(sqxtest.New())
+		fault := db.Always().WillReturnError(sqxtest.ErrBadConn)
+
+		_, err := sqx.Read[Widget](ctx).WithQueryable(db).WithRetryPolicy(policy).Select("*").From("widgets").All()
+		assert.ErrorIs(t, err, driver.ErrBadConn)
+		assert.Equal(t, 3, fault.Injected())
+	})
+
+	t.Run("Does not retry errors that are not retryable", func(t *testing.T) {
+		db := sqxtest.InjectFaults(sqxtest.New())
+		fault := db.Always().WillReturnError(errors.New("syntax error"))
+
+		_, err := sqx.Read[Widget](ctx).WithQueryable(db).WithRetryPolicy(policy).Select("*").From("widgets").All()
+		assert.Error(t, err)
+		assert.Equal(t, 1, fault.Injected())
+	})
+
+	t.Run("Only retries idempotent writes", func(t *testing.T) {
+		db := sqxtest.InjectFaults(sqxtest.New())
+		fault := db.Always().Times(1).WillReturnError(sqxtest.ErrBadConn)
+		w := sqx.Write(ctx).WithQueryable(db).WithRetryPolicy(policy)
+
+		err := w.Update("widgets").Set("status", "great").Do()
+		assert.ErrorIs(t, err, driver.ErrBadConn)
+		fault.Times(1)
+		assert.NoError(t, w.Update("widgets").Set("status", "great").Idempotent().Do())
+		fault.Times(1)
+		assert.NoError(t, w.Insert("widgets").SetMap(map[string]any{"id": 1}).Idempotent().Do())
+		fault.Times(1)
+		assert.NoError(t, w.Delete("widgets").Idempotent().Do())
+		assert.Equal(t, 4, fault.Injected())
+	})
+
+	t.Run("Never retries inside a transaction", func(t *testing.T) {
+		fake := sqxtest.New()
+		fake.ExpectQuery("SELECT * FROM widgets").WillReturnError(sqxtest.ErrDeadlock)
+		fake.ExpectQuery("SELECT * FROM widgets")
+		tx, err := fake.DB().BeginTx(ctx, nil)
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		_, err = sqx.Read[Widget](ctx).WithQueryable(tx).WithRetryPolicy(policy).Select("*").From("widgets").All()
+		assert.ErrorIs(t, err, sqxtest.ErrDeadlock)
+		assert.Len(t, fake.Calls(), 1)
+	})
+
+	t.Run("Never retries inside a wrapped transaction", func(t *testing.T) {
+		fake := sqxtest.New()
+		tx, err := fake.DB().BeginTx(ctx, nil)
+		require.NoError(t, err)
+		defer tx.Rollback()
+		db := sqxtest.InjectFaults(tx)
+		fault := db.Always().WillReturnError(sqxtest.ErrDeadlock)
+
+		_, err = sqx.Read[Widget](ctx).WithQueryable(db).WithRetryPolicy(policy).Select("*").From("widgets").All()
+		assert.ErrorIs(t, err, sqxtest.ErrDeadlock)
+		assert.Equal(t, 1, fault.Injected())
+	})
+
+	t.Run("Uses the default retry policy", func(t *testing.T) {
+		sqx.SetDefaultRetryPolicy(policy)
+		defer sqx.SetDefaultRetryPolicy(sqx.RetryPolicy{})
+		db := sqxtest.InjectFaults(sqxtest.New())
+		db.Always().Times(1).WillReturnError(sqxtest.ErrBadConn)
+
+		_, err := sqx.Read[Widget](ctx).WithQueryable(db).Select("*").From("widgets").All()
+		assert.NoError(t, err)
+	})
+
+	t.Run("Stops retrying when the ctx is done", func(t *testing.T) {
+		db := sqxtest.InjectFaults(sqxtest.New())
+		fault := db.Always().WillReturnError(sqxtest.ErrBadConn)
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+
+		_, err := sqx.Read[Widget](ctx).
+			WithQueryable(db).
+			WithRetryPolicy(sqx.RetryPolicy{MaxAttempts: 10, Backoff: time.Second}).
+			Select("*").
+			From("widgets").
+			All()
+		assert.ErrorIs(t, err, driver.ErrBadConn)
+		assert.Equal(t, 1, fault.Injected())
+	})
+}
+
+func TestIsRetryable(t *testing.T) {
+	assert.True(t, sqx.IsRetryable(driver.ErrBadConn))
+	assert.True(t, sqx.IsRetryable(fmt.Errorf("query failed: %w", sqxtest.ErrDeadlock)))
+	assert.True(t, sqx.IsRetryable(fmt.Errorf("read: %w", syscall.ECONNRESET)))
+	assert.False(t, sqx.IsRetryable(context.DeadlineExceeded))
+	assert.False(t, sqx.IsRetryable(errors.New("duplicate entry")))
+	assert.False(t, sqx.IsRetryable(nil))
+}
@@ -0,0 +1,163 @@
+package sqx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrUntrackedContext is returned by a Router for statements whose context was not passed to TrackWrites or UsePrimary,
+// since the Router could not otherwise send reads after a write to the primary.
+var ErrUntrackedContext = errors.New("untracked context: pass the context to TrackWrites or UsePrimary before using a Router")
+
+// DefaultReadYourWritesWindow is how long reads are sent to the primary after a write in the same context, unless the
+// Router is configured otherwise.
+const DefaultReadYourWritesWindow = 5 * time.Second
+
+// Replica is a read replica in a Router, along with its share of the reads.
+type Replica struct {
+	Queryable Queryable
+	// Weight is the relative share of reads sent to the replica. Weights less than 1 are treated as 1.
+	Weight int
+}
+
+// Router is a Queryable that sends writes to a primary database and reads to a pool of replicas. Statements run with
+// ExecContext are writes, and statements run with QueryContext or QueryRowContext are reads. Reads are spread across
+// the replicas using weighted round-robin.
+//
+// Reads are sent to the primary instead if the context was passed to UsePrimary, or if a write was made with it within
+// the read-your-writes window. Writes are recorded in the tracker attached by TrackWrites, so every statement must use a
+// context passed to TrackWrites or UsePrimary - others fail with ErrUntrackedContext. QueryRowContext cannot return an
+// error, so it sends reads with such a context to the primary instead.
+//
+// Transactions should be opened on the primary directly and passed to builders with WithQueryable.
+type Router struct {
+	primary Queryable
+
+	mu       sync.Mutex
+	replicas []Replica
+	current  []int
+	window   time.Duration
+}
+
+// NewRouter creates a Router that sends writes to primary and reads to replicas. If there are no replicas, everything is
+// sent to the primary.
+func NewRouter(primary Queryable, replicas ...Replica) *Router {
+	return &Router{
+		primary:  primary,
+		replicas: replicas,
+		current:  make([]int, len(replicas)),
+		window:   DefaultReadYourWritesWindow,
+	}
+}
+
+// SetReadYourWritesWindow sets how long reads are sent to the primary after a write in the same context.
+func (r *Router) SetReadYourWritesWindow(window time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.window = window
+}
+
+// ExecContext runs a write against the primary, and records it in the context's write tracker.
+func (r *Router) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	tracker := writeTrackerFromContext(ctx)
+	if tracker == nil && !usesPrimary(ctx) {
+		return nil, ErrUntrackedContext
+	}
+	if tracker != nil {
+		tracker.record()
+	}
+	return r.primary.ExecContext(ctx, query, args...)
+}
+
+// QueryContext runs a read against a replica, or the primary. See Router.
+func (r *Router) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if writeTrackerFromContext(ctx) == nil && !usesPrimary(ctx) {
+		return nil, ErrUntrackedContext
+	}
+	return r.forRead(ctx).QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext runs a read against a replica, or the primary. See Router.
+func (r *Router) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return r.forRead(ctx).QueryRowContext(ctx, query, args...)
+}
+
+// forRead picks the Queryable to run a read for ctx against. Reads with an untracked ctx go to the primary.
+func (r *Router) forRead(ctx context.Context) Queryable {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.replicas) == 0 || usesPrimary(ctx) {
+		return r.primary
+	}
+	if tracker := writeTrackerFromContext(ctx); tracker == nil || tracker.wroteWithin(r.window) {
+		return r.primary
+	}
+	return r.nextReplica()
+}
+
+// nextReplica picks a replica using smooth weighted round-robin, which spreads the picks for each replica evenly
+// instead of sending a run of reads to the heaviest one. r.mu must be held.
+func (r *Router) nextReplica() Queryable {
+	total := 0
+	best := 0
+	for i, replica := range r.replicas {
+		weight := replica.Weight
+		if weight < 1 {
+			weight = 1
+		}
+		r.current[i] += weight
+		total += weight
+		if r.current[i] > r.current[best] {
+			best = i
+		}
+	}
+	r.current[best] -= total
+	return r.replicas[best].Queryable
+}
+
+type usePrimaryKey struct{}
+
+// UsePrimary returns a copy of ctx whose reads are sent to the primary by a Router. See also SelectBuilder.Primary.
+func UsePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, usePrimaryKey{}, true)
+}
+
+func usesPrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(usePrimaryKey{}).(bool)
+	return primary
+}
+
+type writeTrackerKey struct{}
+
+// writeTracker records when the last write was made in a context tracked with TrackWrites.
+type writeTracker struct {
+	mu        sync.Mutex
+	lastWrite time.Time
+}
+
+// TrackWrites returns a copy of ctx in which a Router remembers writes, so that reads made with ctx (or contexts derived
+// from it) shortly after a write are sent to the primary and see the write. A Router requires it: call it once per unit
+// of work, such as in the middleware that handles a request or at the start of a background job.
+func TrackWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, writeTrackerKey{}, &writeTracker{})
+}
+
+func writeTrackerFromContext(ctx context.Context) *writeTracker {
+	tracker, _ := ctx.Value(writeTrackerKey{}).(*writeTracker)
+	return tracker
+}
+
+func (t *writeTracker) record() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lastWrite = time.Now()
+}
+
+func (t *writeTracker) wroteWithin(window time.Duration) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return !t.lastWrite.IsZero() && time.Since(t.lastWrite) < window
+}
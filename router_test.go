@@ -0,0 +1,102 @@
+package sqx_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stytchauth/sqx"
+	"github.com/stytchauth/sqx/sqxtest"
+)
+
+func TestRouter(t *testing.T) {
+	readWidgets := func(ctx context.Context, db sqx.Queryable) {
+		_, err := sqx.Read[Widget](ctx).WithQueryable(db).Select("*").From("widgets").All()
+		require.NoError(t, err)
+	}
+	writeWidget := func(ctx context.Context, db sqx.Queryable) {
+		require.NoError(t, sqx.Write(ctx).WithQueryable(db).Insert("widgets").SetMap(map[string]any{"id": 1}).Do())
+	}
+
+	t.Run("Sends writes to the primary and reads to the replicas", func(t *testing.T) {
+		primary, replica := sqxtest.New(), sqxtest.New()
+		router := sqx.NewRouter(primary, sqx.Replica{Queryable: replica})
+		router.SetReadYourWritesWindow(0)
+		ctx := sqx.TrackWrites(context.Background())
+
+		writeWidget(ctx, router)
+		readWidgets(ctx, router)
+		readWidgets(ctx, router)
+
+		assert.Len(t, primary.Calls(), 1)
+		assert.Len(t, replica.Calls(), 2)
+	})
+
+	t.Run("Balances reads by weight", func(t *testing.T) {
+		primary, heavy, light := sqxtest.New(), sqxtest.New(), sqxtest.New()
+		router := sqx.NewRouter(primary, sqx.Replica{Queryable: heavy, Weight: 3}, sqx.Replica{Queryable: light, Weight: 1})
+
+		for i := 0; i < 8; i++ {
+			readWidgets(sqx.TrackWrites(context.Background()), router)
+		}
+		assert.Len(t, primary.Calls(), 0)
+		assert.Len(t, heavy.Calls(), 6)
+		assert.Len(t, light.Calls(), 2)
+	})
+
+	t.Run("Reads your writes within the window", func(t *testing.T) {
+		primary, replica := sqxtest.New(), sqxtest.New()
+		router := sqx.NewRouter(primary, sqx.Replica{Queryable: replica})
+		router.SetReadYourWritesWindow(50 * time.Millisecond)
+		ctx := sqx.TrackWrites(context.Background())
+
+		readWidgets(ctx, router)
+		writeWidget(ctx, router)
+		readWidgets(ctx, router)
+		readWidgets(sqx.TrackWrites(context.Background()), router)
+		assert.Len(t, primary.Calls(), 2)
+		assert.Len(t, replica.Calls(), 2)
+
+		time.Sleep(50 * time.Millisecond)
+		readWidgets(ctx, router)
+		assert.Len(t, replica.Calls(), 3)
+	})
+
+	t.Run("Primary forces reads to the primary", func(t *testing.T) {
+		primary, replica := sqxtest.New(), sqxtest.New()
+		router := sqx.NewRouter(primary, sqx.Replica{Queryable: replica})
+
+		_, err := sqx.Read[Widget](sqx.TrackWrites(context.Background())).WithQueryable(router).Select("*").From("widgets").Primary().All()
+		require.NoError(t, err)
+		readWidgets(sqx.UsePrimary(context.Background()), router)
+		assert.Len(t, primary.Calls(), 2)
+		assert.Len(t, replica.Calls(), 0)
+	})
+
+	t.Run("Sends everything to the primary without replicas", func(t *testing.T) {
+		primary := sqxtest.New()
+		router := sqx.NewRouter(primary)
+
+		readWidgets(sqx.TrackWrites(context.Background()), router)
+		assert.Len(t, primary.Calls(), 1)
+	})
+
+	t.Run("Fails for untracked contexts", func(t *testing.T) {
+		primary, replica := sqxtest.New(), sqxtest.New()
+		router := sqx.NewRouter(primary, sqx.Replica{Queryable: replica})
+		ctx := context.Background()
+
+		err := sqx.Write(ctx).WithQueryable(router).Insert("widgets").SetMap(map[string]any{"id": 1}).Do()
+		assert.ErrorIs(t, err, sqx.ErrUntrackedContext)
+		_, err = sqx.Read[Widget](ctx).WithQueryable(router).Select("*").From("widgets").All()
+		assert.ErrorIs(t, err, sqx.ErrUntrackedContext)
+		assert.Empty(t, primary.Calls())
+		assert.Empty(t, replica.Calls())
+
+		_ = router.QueryRowContext(ctx, "SELECT 1")
+		assert.Len(t, primary.Calls(), 1, "QueryRowContext falls back to the primary")
+	})
+}
@@ -0,0 +1,63 @@
+package sqx
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/blockloop/scan/v2"
+
+	"github.com/stytchauth/sqx/internal/dbtag"
+)
+
+// AllScalar returns the single column of every row as a slice of T, e.g. the IDs matching a query. T may be any type
+// a single column can be scanned into: a primitive, a sql.Null* type, time.Time, uuid.UUID or any other sql.Scanner.
+// It returns an error if T is not such a type or if the query returns more than one column.
+//
+//	ids, err := sqx.Read[string](ctx).Select("id").From("users").Where(sqx.Eq{"status": "active"}).AllScalar()
+func (b SelectBuilder[T]) AllScalar() ([]T, error) {
+	itemType := reflect.TypeOf((*T)(nil)).Elem()
+	if !isScalarType(itemType) {
+		return nil, fmt.Errorf("AllScalar cannot scan into %s: not a scalar type", itemType)
+	}
+	return queryRows(b, scanRows[T])
+}
+
+// Pluck runs the query of b with its columns replaced by column, and returns that column of every row as a slice of T.
+// It allows reusing a builder for a struct type - with its filters, scopes and soft-delete handling - to read a single
+// column. See AllScalar for the supported types. Relations loaded with With are ignored.
+//
+//	active := sqx.Read[User](ctx).Select("*").From("users").Where(sqx.Eq{"status": "active"})
+//	emails, err := sqx.Pluck[string](active, "email")
+func Pluck[T, U any](b SelectBuilder[U], column string) ([]T, error) {
+	return SelectBuilder[T]{
+		builder:    b.builder.RemoveColumns().Columns(column),
+		queryable:  b.queryable,
+		ctx:        b.ctx,
+		err:        b.err,
+		logger:     b.logger,
+		from:       b.from,
+		softDelete: b.softDelete,
+		lock:       b.lock,
+		timeout:    b.timeout,
+		retry:      b.retry,
+	}.AllScalar()
+}
+
+// isScalarType reports whether a whole row is scanned into a single value of type t, rather than into struct fields or
+// a map.
+func isScalarType(t reflect.Type) bool {
+	if t == mapType {
+		return false
+	}
+	return t.Kind() != reflect.Struct || dbtag.IsScannable(t)
+}
+
+// checkScalarColumns returns an error unless cols holds exactly one column to scan into a value of type t.
+func checkScalarColumns(t reflect.Type, cols []string) error {
+	if len(cols) <= 1 {
+		return nil
+	}
+	return fmt.Errorf("%w: cannot scan %d columns (%s) into %s, select exactly one",
+		scan.ErrTooManyColumns, len(cols), strings.Join(cols, ", "), t)
+}
@@ -0,0 +1,99 @@
+package sqx_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/blockloop/scan/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stytchauth/sqx"
+	"github.com/stytchauth/sqx/sqxtest"
+)
+
+// upperString is a custom sql.Scanner used to check that AllScalar scans through Scan methods.
+type upperString string
+
+func (s *upperString) Scan(src any) error {
+	str, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("cannot scan %T into upperString", src)
+	}
+	*s = upperString(fmt.Sprintf("%s!", str))
+	return nil
+}
+
+func TestAllScalar(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Scans primitives and scanners", func(t *testing.T) {
+		created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		id := uuid.New()
+		fake := sqxtest.New()
+		fake.ExpectQuery("SELECT a FROM things").WillReturnRows(sqxtest.NewRows("a").AddRow(created))
+		fake.ExpectQuery("SELECT a FROM things").WillReturnRows(sqxtest.NewRows("a").AddRow("x").AddRow(nil))
+		fake.ExpectQuery("SELECT a FROM things").WillReturnRows(sqxtest.NewRows("a").AddRow(id.String()))
+		fake.ExpectQuery("SELECT a FROM things").WillReturnRows(sqxtest.NewRows("a").AddRow("hi"))
+		fake.ExpectQuery("SELECT a FROM things").WillReturnRows(sqxtest.NewRows("a").AddRow(int64(1)).AddRow(int64(2)))
+
+		times, err := sqx.Read[time.Time](ctx).WithQueryable(fake).Select("a").From("things").AllScalar()
+		require.NoError(t, err)
+		assert.Equal(t, []time.Time{created}, times)
+
+		strs, err := sqx.Read[sql.NullString](ctx).WithQueryable(fake).Select("a").From("things").AllScalar()
+		require.NoError(t, err)
+		assert.Equal(t, []sql.NullString{{String: "x", Valid: true}, {}}, strs)
+
+		ids, err := sqx.Read[uuid.UUID](ctx).WithQueryable(fake).Select("a").From("things").AllScalar()
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{id}, ids)
+
+		custom, err := sqx.Read[upperString](ctx).WithQueryable(fake).Select("a").From("things").AllScalar()
+		require.NoError(t, err)
+		assert.Equal(t, []upperString{"hi!"}, custom)
+
+		ints, err := sqx.Read[int](ctx).WithQueryable(fake).Select("a").From("things").AllScalar()
+		require.NoError(t, err)
+		assert.Equal(t, []int{1, 2}, ints)
+		fake.AssertExpectations(t)
+	})
+
+	t.Run("Requires exactly one column", func(t *testing.T) {
+		fake := sqxtest.New()
+		fake.ExpectQuery("SELECT * FROM things").WillReturnRows(sqxtest.NewRows("a", "b"))
+
+		_, err := sqx.Read[string](ctx).WithQueryable(fake).Select("*").From("things").AllScalar()
+		assert.ErrorIs(t, err, scan.ErrTooManyColumns)
+		assert.ErrorContains(t, err, "cannot scan 2 columns (a, b) into string")
+	})
+
+	t.Run("Rejects struct types", func(t *testing.T) {
+		_, err := sqx.Read[Widget](ctx).WithQueryable(sqxtest.New()).Select("*").From("things").AllScalar()
+		assert.ErrorContains(t, err, "AllScalar cannot scan into sqx_test.Widget: not a scalar type")
+	})
+
+	t.Run("Plucks a column from a struct query", func(t *testing.T) {
+		tx := Tx(t)
+		setupTestWidgetsTable(t, tx)
+		dbWidget := newDBWidget()
+		for _, w := range []Widget{{ID: "w1", Status: "great"}, {ID: "w2", Status: "fine"}, {ID: "w3", Status: "great"}} {
+			w := w
+			require.NoError(t, dbWidget.Create(ctx, tx, &w))
+		}
+
+		great := sqx.Read[Widget](ctx).
+			WithQueryable(tx).
+			Select("*").
+			From("sqx_widgets_test").
+			Where(sqx.Eq{"status": "great"}).
+			OrderBy("widget_id")
+		ids, err := sqx.Pluck[string](great, "widget_id")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"w1", "w3"}, ids)
+	})
+}
@@ -0,0 +1,45 @@
+package sqx_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stytchauth/sqx"
+)
+
+type ScanAudit struct {
+	CreatedBy string `db:"created_by"`
+}
+
+type scanVersionedWidget struct {
+	ID      string `db:"widget_id"`
+	Version int    `db:"version,version"`
+	ScanAudit
+}
+
+func TestScanTagOptions(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Scans columns into fields whose db tags have options", func(t *testing.T) {
+		tx := Tx(t)
+		w, err := sqx.Read[scanVersionedWidget](ctx).
+			WithQueryable(tx).
+			Select("'w1' AS widget_id", "3 AS version", "'me' AS created_by", "'x' AS unknown").
+			OneStrict()
+		require.NoError(t, err)
+		assert.Equal(t, &scanVersionedWidget{ID: "w1", Version: 3, ScanAudit: ScanAudit{CreatedBy: "me"}}, w)
+	})
+
+	t.Run("Strips db tag options from column names", func(t *testing.T) {
+		sql, args, err := sqx.ToClause(&struct {
+			ID      *string `db:"widget_id"`
+			Version *int    `db:"version,version"`
+		}{ID: sqx.Ptr("w1"), Version: sqx.Ptr(3)}).ToSql()
+		require.NoError(t, err)
+		assert.Equal(t, "version = ? AND widget_id = ?", sql)
+		assert.Equal(t, []any{3, "w1"}, args)
+	})
+}
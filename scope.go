@@ -0,0 +1,233 @@
+package sqx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrMissingScope is returned when a table registered with RegisterScopedTable is queried with a ctx that has no value
+// for one of the table's scope columns. See WithScope.
+var ErrMissingScope = errors.New("missing scope")
+
+// ErrScopeViolation is returned when a row inserted into or updated in a scoped table sets a scope column to a value
+// other than the one in the ctx scope.
+var ErrScopeViolation = errors.New("scope violation")
+
+var (
+	scopedTablesMu sync.RWMutex
+	scopedTables   = map[string][]string{}
+)
+
+// RegisterScopedTable marks table as scoped by the given columns, such as "project_id". Once registered, every
+// SelectBuilder, UpdateBuilder and DeleteBuilder on the table has a predicate for each column added to its WHERE
+// clause, and every InsertBuilder.SetMap or InsertManyBuilder.FromItems on the table has each column set, using the
+// values from the ctx scope.
+// Queries on the table fail with ErrMissingScope if the ctx scope does not have a value for every column, and writes
+// fail with ErrScopeViolation if they insert or update a scope column with any other value.
+//
+// Only the table passed to From, Update, Delete or Insert is scoped - joined tables are not.
+//
+// The registration is read when a builder's table is set, and again when an insert runs, so builders created before
+// the table was registered are not scoped. Registering the table again replaces its columns, and tables cannot be
+// unregistered. RegisterScopedTable is safe for concurrent use.
+func RegisterScopedTable(table string, columns ...string) {
+	scopedTablesMu.Lock()
+	defer scopedTablesMu.Unlock()
+	scopedTables[table] = columns
+}
+
+type scopeKey struct{}
+
+// WithScope returns a copy of ctx which carries the given scope, e.g. sqx.Eq{"project_id": projectID}. If ctx already
+// carries a scope, the two are merged.
+func WithScope(ctx context.Context, scope Eq) context.Context {
+	merged := Eq{}
+	for column, value := range ScopeFromContext(ctx) {
+		merged[column] = value
+	}
+	for column, value := range scope {
+		merged[column] = value
+	}
+	return context.WithValue(ctx, scopeKey{}, merged)
+}
+
+// ScopeFromContext returns the scope attached to ctx by WithScope, or nil if there is none.
+func ScopeFromContext(ctx context.Context) Eq {
+	if ctx == nil {
+		return nil
+	}
+	scope, _ := ctx.Value(scopeKey{}).(Eq)
+	return scope
+}
+
+// scopeValues returns the scope column values that apply to the table referenced by from, keyed by column name. If
+// qualify is set, the column names are qualified with the table's alias (or name). It returns nil if the table is not
+// scoped, and ErrMissingScope if the ctx scope is missing a value.
+func scopeValues(ctx context.Context, from string, qualify bool) (Eq, error) {
+	table, alias := parseTableRef(from)
+	scopedTablesMu.RLock()
+	columns, ok := scopedTables[table]
+	scopedTablesMu.RUnlock()
+	if !ok {
+		return nil, nil
+	}
+
+	scope := ScopeFromContext(ctx)
+	values := make(Eq, len(columns))
+	for _, column := range columns {
+		value, ok := scope[column]
+		if !ok {
+			return nil, fmt.Errorf("%w: table %q requires a value for %q", ErrMissingScope, table, column)
+		}
+		if qualify {
+			values[alias+"."+column] = value
+		} else {
+			values[column] = value
+		}
+	}
+	return values, nil
+}
+
+// scopePredicate returns the WHERE predicate restricting the table referenced by from to the ctx scope, or nil if the
+// table is not scoped.
+func scopePredicate(ctx context.Context, from string) (Sqlizer, error) {
+	values, err := scopeValues(ctx, from, true)
+	if err != nil || values == nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// scopeSetMap returns a copy of clauses with the ctx scope values for table set. It returns ErrScopeViolation if
+// clauses already sets a scope column to a different value.
+func scopeSetMap(ctx context.Context, table string, clauses map[string]any) (map[string]any, error) {
+	values, err := scopeValues(ctx, table, false)
+	if err != nil || values == nil {
+		return clauses, err
+	}
+	scoped := make(map[string]any, len(clauses)+len(values))
+	for column, value := range clauses {
+		scoped[column] = value
+	}
+	for column, value := range values {
+		if existing, ok := scoped[column]; ok {
+			if err := checkScopeValue(values, column, existing); err != nil {
+				return nil, err
+			}
+		}
+		scoped[column] = value
+	}
+	return scoped, nil
+}
+
+// checkScopeValue returns ErrScopeViolation if column is a scope column and value does not match the scope's value.
+func checkScopeValue(scope Eq, column string, value any) error {
+	expected, ok := scope[column]
+	if !ok || reflect.DeepEqual(indirect(value), indirect(expected)) {
+		return nil
+	}
+	return fmt.Errorf("%w: %q must be %v", ErrScopeViolation, column, indirect(expected))
+}
+
+// checkScopeSet returns ErrScopeViolation if column, which may be qualified with the table's alias, is a scope column of
+// the table referenced by from and value does not match the ctx scope's value.
+func checkScopeSet(ctx context.Context, from string, column string, value any) error {
+	scope, err := scopeValues(ctx, from, false)
+	if err != nil || scope == nil {
+		return err
+	}
+	if _, alias := parseTableRef(from); strings.HasPrefix(column, alias+".") {
+		column = strings.TrimPrefix(column, alias+".")
+	}
+	return checkScopeValue(scope, column, value)
+}
+
+// insertedRows records the columns and rows of an INSERT statement, so that its scope columns can be checked before it
+// runs. Rows are kept in a persistent list so that copies of a builder can add rows without affecting each other.
+type insertedRows struct {
+	columns []string
+	last    *insertedRow
+}
+
+type insertedRow struct {
+	values []any
+	prev   *insertedRow
+}
+
+// withColumns returns a copy of r with columns added.
+func (r insertedRows) withColumns(columns ...string) insertedRows {
+	r.columns = append(append([]string{}, r.columns...), columns...)
+	return r
+}
+
+// withValues returns a copy of r with a row of values added.
+func (r insertedRows) withValues(values ...any) insertedRows {
+	r.last = &insertedRow{values: values, prev: r.last}
+	return r
+}
+
+// withSetMap returns the insertedRows for a single row set from clauses, replacing any previous columns and rows.
+func withSetMap(clauses map[string]any) insertedRows {
+	columns := sortedKeys(clauses)
+	values := make([]any, len(columns))
+	for i, column := range columns {
+		values[i] = clauses[column]
+	}
+	return insertedRows{}.withColumns(columns...).withValues(values...)
+}
+
+// checkScope returns ErrScopeViolation unless every row sets each scope column of table to its value in the ctx scope.
+func (r insertedRows) checkScope(ctx context.Context, table string) error {
+	scope, err := scopeValues(ctx, table, false)
+	if err != nil || scope == nil {
+		return err
+	}
+	for _, column := range sortedKeys(scope) {
+		if !contains(r.columns, column) || r.last == nil {
+			return errScopeNotApplied(table)
+		}
+	}
+	for row := r.last; row != nil; row = row.prev {
+		if len(row.values) != len(r.columns) {
+			return errScopeNotApplied(table)
+		}
+		for i, column := range r.columns {
+			if err := checkScopeValue(scope, column, row.values[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// indirect dereferences v until it is no longer a non-nil pointer.
+func indirect(v any) any {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr && !value.IsNil() {
+		value = value.Elem()
+	}
+	if !value.IsValid() {
+		return nil
+	}
+	return value.Interface()
+}
+
+// errScopeNotApplied is returned when rows are inserted into a scoped table without a value for every scope column.
+func errScopeNotApplied(table string) error {
+	return fmt.Errorf("%w: rows inserted into %q must set every scope column - use SetMap or FromItems", ErrScopeViolation, table)
+}
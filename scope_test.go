@@ -0,0 +1,142 @@
+package sqx
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type scopedThingy struct {
+	ID        string `db:"id"`
+	ProjectID string `db:"project_id"`
+}
+
+func TestScope(t *testing.T) {
+	RegisterScopedTable("sqx_scoped_things", "project_id")
+	ctx := WithScope(context.Background(), Eq{"project_id": "project-123"})
+
+	t.Run("Select adds the scope predicate", func(t *testing.T) {
+		b := Read[string](ctx).Select("t.id").From("sqx_scoped_things t").Where(Eq{"t.id": "123"})
+		require.NoError(t, b.err)
+		query, args, err := b.finalBuilder().ToSql()
+		require.NoError(t, err)
+		assert.Equal(t, "SELECT t.id FROM sqx_scoped_things t WHERE t.project_id = ? AND t.id = ?", query)
+		assert.Equal(t, []any{"project-123", "123"}, args)
+	})
+
+	t.Run("Update adds the scope predicate", func(t *testing.T) {
+		b := Write(ctx).Update("sqx_scoped_things").Set("name", "new").Where(Eq{"id": "123"})
+		require.NoError(t, b.err)
+		query, args, err := b.finalBuilder().ToSql()
+		require.NoError(t, err)
+		assert.Equal(t, "UPDATE sqx_scoped_things SET name = ? WHERE sqx_scoped_things.project_id = ? AND id = ?", query)
+		assert.Equal(t, []any{"new", "project-123", "123"}, args)
+	})
+
+	t.Run("Delete adds the scope predicate", func(t *testing.T) {
+		b := Write(ctx).Delete("sqx_scoped_things").Where(Eq{"id": "123"})
+		require.NoError(t, b.err)
+		query, args, err := b.builder.ToSql()
+		require.NoError(t, err)
+		assert.Equal(t, "DELETE sqx_scoped_things FROM sqx_scoped_things WHERE sqx_scoped_things.project_id = ? AND id = ?", query)
+		assert.Equal(t, []any{"project-123", "123"}, args)
+	})
+
+	t.Run("Insert sets the scope columns", func(t *testing.T) {
+		b := Write(ctx).Insert("sqx_scoped_things").SetMap(map[string]any{"id": "123"})
+		require.NoError(t, b.err)
+		query, args, err := b.builder.ToSql()
+		require.NoError(t, err)
+		assert.Equal(t, "INSERT INTO sqx_scoped_things (id,project_id) VALUES (?,?)", query)
+		assert.Equal(t, []any{"123", "project-123"}, args)
+	})
+
+	t.Run("InsertMany sets the scope columns", func(t *testing.T) {
+		type thingy struct {
+			ID string `db:"id"`
+		}
+		b := TypedWrite[thingy](ctx).InsertMany("sqx_scoped_things").FromItems([]thingy{{ID: "123"}, {ID: "456"}})
+		require.NoError(t, b.err)
+		query, args, err := b.builder.ToSql()
+		require.NoError(t, err)
+		assert.Equal(t, "INSERT INTO sqx_scoped_things (id,project_id) VALUES (?,?),(?,?)", query)
+		assert.Equal(t, []any{"123", "project-123", "456", "project-123"}, args)
+	})
+
+	t.Run("Insert fails when a scope column is set to another value", func(t *testing.T) {
+		err := Write(ctx).Insert("sqx_scoped_things").SetMap(map[string]any{"id": "123", "project_id": Ptr("project-456")}).Do()
+		assert.ErrorIs(t, err, ErrScopeViolation)
+
+		err = TypedWrite[scopedThingy](ctx).
+			InsertMany("sqx_scoped_things").
+			FromItems([]scopedThingy{{ID: "123", ProjectID: "project-123"}, {ID: "456", ProjectID: "project-456"}}).
+			Do()
+		assert.ErrorIs(t, err, ErrScopeViolation)
+	})
+
+	t.Run("Insert fails when the scope columns were not set", func(t *testing.T) {
+		err := Write(ctx).Insert("sqx_scoped_things").Columns("id").Values("123").Do()
+		assert.ErrorIs(t, err, ErrScopeViolation)
+	})
+
+	t.Run("Insert checks columns added after SetMap", func(t *testing.T) {
+		b := Write(ctx).Insert("sqx_scoped_things").SetMap(map[string]any{"id": "123"})
+		err := b.Columns("project_id").Values("project-456").Do()
+		assert.ErrorIs(t, err, ErrScopeViolation, "row without the scope")
+
+		err = Write(ctx).Insert("sqx_scoped_things").Columns("id", "project_id").Values("123", "project-456").Do()
+		assert.ErrorIs(t, err, ErrScopeViolation, "row with another scope")
+
+		err = TypedWrite[scopedThingy](ctx).
+			InsertMany("sqx_scoped_things").
+			FromItems([]scopedThingy{{ID: "123", ProjectID: "project-123"}}).
+			Values("456", "project-456").
+			Do()
+		assert.ErrorIs(t, err, ErrScopeViolation, "row added after FromItems")
+	})
+
+	t.Run("Insert allows rows set with the scope", func(t *testing.T) {
+		ctx := DryRun(ctx)
+		err := Write(ctx).Insert("sqx_scoped_things").Columns("id", "project_id").Values("123", Ptr("project-123")).Do()
+		require.NoError(t, err)
+		assert.Len(t, CollectorFromContext(ctx).Statements(), 1)
+	})
+
+	t.Run("Update fails when a scope column is set to another value", func(t *testing.T) {
+		err := Write(ctx).Update("sqx_scoped_things").Set("project_id", "project-456").Where(Eq{"id": "123"}).Do()
+		assert.ErrorIs(t, err, ErrScopeViolation)
+
+		err = Write(ctx).Update("sqx_scoped_things t").Set("t.project_id", "project-456").Where(Eq{"t.id": "123"}).Do()
+		assert.ErrorIs(t, err, ErrScopeViolation)
+
+		err = Write(ctx).
+			Update("sqx_scoped_things").
+			SetMap(ToSetMap(&scopedThingy{ProjectID: "project-456"}, "id")).
+			Where(Eq{"id": "123"}).
+			Do()
+		assert.ErrorIs(t, err, ErrScopeViolation)
+	})
+
+	t.Run("Update allows setting a scope column to its scope value", func(t *testing.T) {
+		b := Write(ctx).Update("sqx_scoped_things").SetMap(ToSetMap(&scopedThingy{ProjectID: "project-123"}, "id"))
+		require.NoError(t, b.err)
+	})
+
+	t.Run("Fails closed when the ctx has no scope", func(t *testing.T) {
+		ctx := context.Background()
+
+		_, err := Read[string](ctx).Select("id").From("sqx_scoped_things").All()
+		assert.ErrorIs(t, err, ErrMissingScope)
+		assert.ErrorIs(t, Write(ctx).Update("sqx_scoped_things").Set("name", "new").Do(), ErrMissingScope)
+		assert.ErrorIs(t, Write(ctx).Delete("sqx_scoped_things").Do(), ErrMissingScope)
+		assert.ErrorIs(t, Write(ctx).Insert("sqx_scoped_things").SetMap(map[string]any{"id": "123"}).Do(), ErrMissingScope)
+	})
+
+	t.Run("Does not scope tables that are not registered", func(t *testing.T) {
+		query, _, err := Read[string](context.Background()).Select("id").From("sqx_other_things").finalBuilder().ToSql()
+		require.NoError(t, err)
+		assert.Equal(t, "SELECT id FROM sqx_other_things", query)
+	})
+}
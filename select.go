@@ -5,18 +5,28 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"sync"
+	"time"
 
-	"github.com/blockloop/scan/v2"
 	sq "github.com/stytchauth/squirrel"
 )
 
 // SelectBuilder wraps squirrel.SelectBuilder and adds syntactic sugar for common usage patterns.
 type SelectBuilder[T any] struct {
-	builder   sq.SelectBuilder
-	queryable Queryable
-	ctx       context.Context
-	err       error
-	logger    Logger
+	builder    sq.SelectBuilder
+	queryable  Queryable
+	ctx        context.Context
+	err        error
+	logger     Logger
+	from       string
+	softDelete softDeleteMode
+	lock       rowLock
+	timeout    time.Duration
+	retry      RetryPolicy
+	relations  []Relation[T]
+	// noRows wraps the query in SELECT * FROM (...) AS t LIMIT 0, so that only its columns are returned. See
+	// ColumnTypes.
+	noRows bool
 }
 
 // ============================================
@@ -66,12 +76,21 @@ func (b SelectBuilder[T]) Column(column any, args ...any) SelectBuilder[T] {
 
 // From sets the FROM clause of the query.
 func (b SelectBuilder[T]) From(from string) SelectBuilder[T] {
-	return b.withBuilder(b.builder.From(from))
+	b.from = from
+	b = b.withBuilder(b.builder.From(from))
+	pred, err := scopePredicate(b.ctx, from)
+	if err != nil {
+		return b.withError(err)
+	} else if pred != nil {
+		return b.Where(pred)
+	}
+	return b
 }
 
 // FromSelect sets a subquery into the FROM clause of the query.
 func (b SelectBuilder[T]) FromSelect(from SelectBuilder[T], alias string) SelectBuilder[T] {
-	return b.withBuilder(b.builder.FromSelect(from.builder, alias))
+	b.from = ""
+	return b.withBuilder(b.builder.FromSelect(from.finalBuilder(), alias))
 }
 
 // JoinClause adds a join clause to the query.
@@ -194,13 +213,50 @@ func (b SelectBuilder[T]) SuffixExpr(expr Sqlizer) SelectBuilder[T] {
 
 // UnionAll adds a UNION ALL clause to the query from another SelectBuilder of the same type.
 func (b SelectBuilder[T]) UnionAll(other SelectBuilder[T]) SelectBuilder[T] {
-	query, args, err := other.builder.ToSql()
+	query, args, err := other.ToSql()
 	if err != nil {
 		return b.withError(err)
 	}
 	return b.withBuilder(b.builder.Suffix("UNION ALL ("+query+")", args...))
 }
 
+// WithDeleted includes soft-deleted rows in the results. See RegisterSoftDelete.
+func (b SelectBuilder[T]) WithDeleted() SelectBuilder[T] {
+	b.softDelete = includeDeleted
+	return b
+}
+
+// OnlyDeleted only returns soft-deleted rows. See RegisterSoftDelete.
+func (b SelectBuilder[T]) OnlyDeleted() SelectBuilder[T] {
+	b.softDelete = onlyDeleted
+	return b
+}
+
+// Timeout limits how long the query may run, overriding the default from SetDefaultTimeouts. The query fails with
+// context.DeadlineExceeded if it runs for longer. On MySQL, the query also gets a MAX_EXECUTION_TIME optimizer hint, so
+// that the server stops running it even if the client has gone away.
+func (b SelectBuilder[T]) Timeout(timeout time.Duration) SelectBuilder[T] {
+	b.timeout = timeout
+	return b
+}
+
+// Primary makes a Router run the query against the primary instead of a replica, e.g. when the result must reflect a
+// write made by another request. See UsePrimary.
+func (b SelectBuilder[T]) Primary() SelectBuilder[T] {
+	if b.ctx != nil {
+		b.ctx = UsePrimary(b.ctx)
+	}
+	return b
+}
+
+// Shard sets the shard key that a ShardedQueryable uses to pick the shard to run the query against. See WithShardKey.
+func (b SelectBuilder[T]) Shard(key any) SelectBuilder[T] {
+	if b.ctx != nil {
+		b.ctx = WithShardKey(b.ctx, key)
+	}
+	return b
+}
+
 // one returns a single result from the query, or an error if there was a problem. It may be run in strict or non-strict
 // mode. In non-strict mode, a warning is logged if more than one result is returned in the query. In strict mode, this
 // turns into an ErrTooManyRows error. If the underlying query is *expected* to return more than one row and this is not
@@ -269,18 +325,14 @@ func (b SelectBuilder[T]) OneScalarStrict() (T, error) {
 // are expected to return more than one result, but you only care about the first one. Note that if you haven't added an
 // ORDER BY clause to your query, the first result is not guaranteed to be the same each time you run the query.
 func (b SelectBuilder[T]) First() (*T, error) {
-	rows, err := b.query()
-	if err != nil {
-		return nil, err
-	}
-
-	var dest T
-	err = scan.RowStrict(&dest, rows)
+	dest, err := b.query()
 	if err != nil {
 		return nil, err
+	} else if len(dest) == 0 {
+		return nil, sql.ErrNoRows
 	}
 
-	return &dest, nil
+	return &dest[0], nil
 }
 
 // FirstScalar is like First but dereferences the result into a scalar value. If an error is raised, the scalar value
@@ -296,58 +348,170 @@ func (b SelectBuilder[T]) FirstScalar() (T, error) {
 
 // All returns all results from the query as a slice of T.
 func (b SelectBuilder[T]) All() ([]T, error) {
-	rows, err := b.query()
-	if err != nil {
+	return b.query()
+}
+
+// AllShards runs the query against every shard of a ShardedQueryable concurrently, and returns the results from all of
+// them in shard order. The shard key is ignored. Note that ORDER BY, LIMIT and OFFSET clauses apply to each shard
+// separately, not to the merged results. Relations set with With are loaded from the shard that each row came from.
+func (b SelectBuilder[T]) AllShards() ([]T, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if b.ctx == nil {
+		return nil, errors.New("no ctx")
+	}
+	if err := b.lock.checkQueryable(b.queryable); err != nil {
 		return nil, err
 	}
+	sharded, ok := b.queryable.(*ShardedQueryable)
+	if !ok {
+		return nil, fmt.Errorf("AllShards requires a *ShardedQueryable - call WithQueryable to set it")
+	}
+
+	builder := b.finalBuilder()
+	shards := sharded.Shards()
+	onShard := b
+	onShard.ctx = withOnShard(b.ctx)
+	results := make([][]T, len(shards))
+	errs := make([]error, len(shards))
+	var wg sync.WaitGroup
+	for i, shard := range shards {
+		wg.Add(1)
+		go func(i int, shard Queryable) {
+			defer wg.Done()
+			results[i], errs[i] = withRetries(b.ctx, b.retry, true, shard, b.logger, func() ([]T, error) {
+				ctx, cancel := withTimeout(b.ctx, b.effectiveTimeout())
+				defer cancel()
+				rows, err := builder.RunWith(runShim{shard}).QueryContext(ctx)
+				if err != nil {
+					return nil, err
+				}
+				return scanRows[T](rows)
+			})
+			if errs[i] == nil {
+				errs[i] = onShard.WithQueryable(shard).loadRelations(results[i])
+			}
+		}(i, shard)
+	}
+	wg.Wait()
 
 	var dest []T
-	err = scan.RowsStrict(&dest, rows)
+	for i := range shards {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("shard %d: %w", i, errs[i])
+		}
+		dest = append(dest, results[i]...)
+	}
+	return dest, nil
+}
 
+// query runs the query and scans the results. The timeout covers both, since rows are streamed from the server while
+// they are scanned.
+func (b SelectBuilder[T]) query() ([]T, error) {
+	dest, err := queryRows(b, scanRows[T])
 	if err != nil {
 		return nil, err
-	} else {
-		return dest, nil
 	}
+	if err := b.loadRelations(dest); err != nil {
+		return nil, err
+	}
+	return dest, nil
 }
 
-func (b SelectBuilder[T]) query() (*sql.Rows, error) {
+// queryRows runs the query of b and reads the result set with read, retrying both if they fail with a transient error.
+func queryRows[T, R any](b SelectBuilder[T], read func(rows *sql.Rows) (R, error)) (R, error) {
+	var none R
 	if b.err != nil {
-		return nil, b.err
+		return none, b.err
 	}
 	if b.queryable == nil {
-		return nil, errors.New("no queryable")
+		return none, errors.New("no queryable")
 	}
 	if b.ctx == nil {
-		return nil, errors.New("no ctx")
+		return none, errors.New("no ctx")
 	}
-	if b.queryable == nil {
-		return nil, fmt.Errorf("missing queryable - call SetDefaultQueryable or WithQueryable to set it")
+	if err := checkShardKey(b.ctx, b.from); err != nil {
+		return none, err
+	}
+	if err := b.lock.validate(); err != nil {
+		return none, err
+	}
+	if err := b.lock.checkQueryable(b.queryable); err != nil {
+		return none, err
+	}
+	return withRetries(b.ctx, b.retry, true, b.queryable, b.logger, func() (R, error) {
+		ctx, cancel := withTimeout(b.ctx, b.effectiveTimeout())
+		defer cancel()
+		rows, err := b.finalBuilder().RunWith(runShim{b.queryable}).QueryContext(ctx)
+		if err != nil {
+			return none, err
+		}
+		return read(rows)
+	})
+}
+
+// effectiveTimeout returns the builder's timeout, or the default timeout for selects.
+func (b SelectBuilder[T]) effectiveTimeout() time.Duration {
+	return timeoutOr(b.timeout, defaultTimeouts.Select)
+}
+
+// finalBuilder returns the underlying squirrel builder with any clauses managed by sqx itself applied: the soft delete
+// predicate, the locking clause, the LIMIT 0 wrapper used by ColumnTypes, and the MAX_EXECUTION_TIME hint if the query
+// has a timeout.
+func (b SelectBuilder[T]) finalBuilder() sq.SelectBuilder {
+	builder := b.builder
+	if pred := softDeletePredicate(b.from, b.softDelete); pred != nil {
+		builder = builder.Where(pred)
+	}
+	if lock := b.lock.String(); lock != "" {
+		builder = builder.Suffix(lock)
 	}
-	return b.builder.RunWith(runShim{b.queryable}).QueryContext(b.ctx)
+	if b.noRows {
+		builder = sq.Select("*").FromSelect(builder, "t").Limit(0)
+	}
+	if timeout := b.effectiveTimeout(); timeout > 0 {
+		builder = withMaxExecutionTimeHint(builder, timeout)
+	}
+	return builder
+}
+
+// ToSql returns the SQL query and args that would be run, or the first error that occurred while building it.
+func (b SelectBuilder[T]) ToSql() (string, []interface{}, error) {
+	if b.err != nil {
+		return "", nil, b.err
+	}
+	if err := b.lock.validate(); err != nil {
+		return "", nil, err
+	}
+	return b.finalBuilder().ToSql()
 }
 
 // Debug prints the SQL query using the builder's logger and then returns b, unmodified. If the builder has no logger
 // set (and SetDefaultLogger has not been called), then log.Printf is used instead.
 func (b SelectBuilder[T]) Debug() SelectBuilder[T] {
-	debug(b.logger, b.builder)
+	debug(b.logger, b)
 	return b
 }
 
 // WithQueryable configures a Queryable for this SelectBuilder instance
 func (b SelectBuilder[T]) WithQueryable(queryable Queryable) SelectBuilder[T] {
-	return SelectBuilder[T]{builder: b.builder, queryable: queryable, logger: b.logger, ctx: b.ctx, err: b.err}
+	b.queryable = queryable
+	return b
 }
 
 // WithLogger configures a Queryable for this SelectBuilder instance
 func (b SelectBuilder[T]) WithLogger(logger Logger) SelectBuilder[T] {
-	return SelectBuilder[T]{builder: b.builder, queryable: b.queryable, logger: logger, ctx: b.ctx, err: b.err}
+	b.logger = logger
+	return b
 }
 
 func (b SelectBuilder[T]) withBuilder(builder sq.SelectBuilder) SelectBuilder[T] {
-	return SelectBuilder[T]{builder: builder, queryable: b.queryable, logger: b.logger, ctx: b.ctx, err: b.err}
+	b.builder = builder
+	return b
 }
 
 func (b SelectBuilder[T]) withError(err error) SelectBuilder[T] {
-	return SelectBuilder[T]{builder: b.builder, queryable: b.queryable, logger: b.logger, ctx: b.ctx, err: err}
+	b.err = err
+	return b
 }
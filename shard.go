@@ -0,0 +1,286 @@
+package sqx
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+)
+
+// ErrMissingShardKey is returned when a table registered with RegisterShardedTable is queried without a shard key, or
+// when a ShardedQueryable without a fallback runs a statement without a shard key. See WithShardKey.
+var ErrMissingShardKey = errors.New("missing shard key")
+
+var (
+	shardedTablesMu sync.RWMutex
+	shardedTables   = map[string]bool{}
+)
+
+// RegisterShardedTable marks table as sharded. Once registered, builders on the table fail with ErrMissingShardKey
+// unless a shard key was supplied with the builder's Shard method or WithShardKey, so that a query can never be sent to
+// the wrong shard by accident. SelectBuilder.AllShards is exempt, since it queries every shard.
+//
+// The check runs each time a statement is run, and RegisterShardedTable is safe to call concurrently with it.
+// Registering a table more than once has no further effect, and tables cannot be unregistered.
+func RegisterShardedTable(table string) {
+	shardedTablesMu.Lock()
+	defer shardedTablesMu.Unlock()
+	shardedTables[table] = true
+}
+
+type shardKey struct{}
+
+// WithShardKey returns a copy of ctx which carries the given shard key, e.g. a customer ID. A ShardedQueryable runs
+// statements made with ctx against the shard that its resolver picks for the key.
+func WithShardKey(ctx context.Context, key any) context.Context {
+	return context.WithValue(ctx, shardKey{}, key)
+}
+
+// ShardKeyFromContext returns the shard key attached to ctx by WithShardKey, and whether there was one.
+func ShardKeyFromContext(ctx context.Context) (any, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	key := ctx.Value(shardKey{})
+	return key, key != nil
+}
+
+type onShardKey struct{}
+
+// withOnShard returns a copy of ctx for statements which run directly against one shard rather than through a
+// ShardedQueryable, such as the relations loaded by AllShards, so that they need no shard key.
+func withOnShard(ctx context.Context) context.Context {
+	return context.WithValue(ctx, onShardKey{}, true)
+}
+
+// checkShardKey returns ErrMissingShardKey if the table referenced by from is sharded and ctx has no shard key, unless
+// ctx is for statements run directly against one shard.
+func checkShardKey(ctx context.Context, from string) error {
+	table, _ := parseTableRef(from)
+	shardedTablesMu.RLock()
+	sharded := shardedTables[table]
+	shardedTablesMu.RUnlock()
+	if !sharded {
+		return nil
+	}
+	if onShard, _ := ctx.Value(onShardKey{}).(bool); onShard {
+		return nil
+	}
+	if _, ok := ShardKeyFromContext(ctx); !ok {
+		return fmt.Errorf("%w for sharded table %q", ErrMissingShardKey, table)
+	}
+	return nil
+}
+
+// ShardResolver picks the shard that holds the data for a shard key.
+type ShardResolver interface {
+	// Resolve returns the shard for key.
+	Resolve(ctx context.Context, key any) (Queryable, error)
+	// Shards returns every shard, in a stable order.
+	Shards() []Queryable
+}
+
+// ShardedQueryable is a Queryable that runs each statement against the shard its resolver picks for the shard key in
+// the statement's ctx. Statements without a shard key run against the fallback, which usually holds the tables that are
+// not sharded. Without a fallback, they fail with ErrMissingShardKey.
+type ShardedQueryable struct {
+	resolver ShardResolver
+	fallback Queryable
+}
+
+// NewShardedQueryable creates a ShardedQueryable. fallback may be nil.
+func NewShardedQueryable(resolver ShardResolver, fallback Queryable) *ShardedQueryable {
+	return &ShardedQueryable{resolver: resolver, fallback: fallback}
+}
+
+// Shards returns every shard, in the order the resolver returns them.
+func (s *ShardedQueryable) Shards() []Queryable {
+	return s.resolver.Shards()
+}
+
+// ExecContext runs an exec against the shard for ctx.
+func (s *ShardedQueryable) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	shard, err := s.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return shard.ExecContext(ctx, query, args...)
+}
+
+// QueryContext runs a query against the shard for ctx.
+func (s *ShardedQueryable) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	shard, err := s.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return shard.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext runs a query against the shard for ctx. If the shard cannot be resolved, the error is returned when
+// the row is scanned.
+func (s *ShardedQueryable) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	shard, err := s.resolve(ctx)
+	if err != nil {
+		return errRow(ctx, err)
+	}
+	return shard.QueryRowContext(ctx, query, args...)
+}
+
+func (s *ShardedQueryable) resolve(ctx context.Context) (Queryable, error) {
+	key, ok := ShardKeyFromContext(ctx)
+	if !ok {
+		if s.fallback == nil {
+			return nil, ErrMissingShardKey
+		}
+		return s.fallback, nil
+	}
+	return s.resolver.Resolve(ctx, key)
+}
+
+// HashResolver spreads keys evenly across a fixed list of shards by hashing them. Keys are hashed by their fmt.Sprint
+// representation, so 42 and "42" map to the same shard.
+//
+// Adding or removing a shard moves most keys to a different shard - use a LookupResolver if shards change over time.
+type HashResolver struct {
+	shards []Queryable
+}
+
+// NewHashResolver creates a HashResolver over shards.
+func NewHashResolver(shards ...Queryable) *HashResolver {
+	return &HashResolver{shards: shards}
+}
+
+// Resolve returns the shard for key.
+func (r *HashResolver) Resolve(_ context.Context, key any) (Queryable, error) {
+	if len(r.shards) == 0 {
+		return nil, errors.New("hash resolver has no shards")
+	}
+	h := fnv.New64a()
+	_, _ = fmt.Fprint(h, key)
+	return r.shards[h.Sum64()%uint64(len(r.shards))], nil
+}
+
+// Shards returns every shard.
+func (r *HashResolver) Shards() []Queryable {
+	return r.shards
+}
+
+// ordered is the set of types which support the < operator.
+type ordered interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64 | ~string
+}
+
+// ShardRange is a shard which holds the keys from From (inclusive) up to the From of the next range.
+type ShardRange[K ordered] struct {
+	From  K
+	Shard Queryable
+}
+
+// RangeResolver assigns contiguous ranges of keys to shards.
+type RangeResolver[K ordered] struct {
+	ranges []ShardRange[K]
+}
+
+// NewRangeResolver creates a RangeResolver over ranges, which may be given in any order.
+func NewRangeResolver[K ordered](ranges ...ShardRange[K]) *RangeResolver[K] {
+	sorted := append([]ShardRange[K]{}, ranges...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].From < sorted[j].From })
+	return &RangeResolver[K]{ranges: sorted}
+}
+
+// Resolve returns the shard whose range contains key. It returns an error if key is not a K, or if it is below the
+// first range.
+func (r *RangeResolver[K]) Resolve(_ context.Context, key any) (Queryable, error) {
+	k, ok := key.(K)
+	if !ok {
+		return nil, fmt.Errorf("range resolver cannot resolve shard key of type %T", key)
+	}
+	i := sort.Search(len(r.ranges), func(i int) bool { return r.ranges[i].From > k })
+	if i == 0 {
+		return nil, fmt.Errorf("shard key %v is not in any range", k)
+	}
+	return r.ranges[i-1].Shard, nil
+}
+
+// Shards returns every shard, in the order of their ranges.
+func (r *RangeResolver[K]) Shards() []Queryable {
+	shards := make([]Queryable, len(r.ranges))
+	for i, rng := range r.ranges {
+		shards[i] = rng.Shard
+	}
+	return shards
+}
+
+// LookupResolver picks shards by name using a lookup function, e.g. one which reads a directory table mapping each
+// customer to the cluster that holds their data.
+type LookupResolver struct {
+	names  []string
+	shards map[string]Queryable
+	lookup func(ctx context.Context, key any) (string, error)
+}
+
+// NewLookupResolver creates a LookupResolver over the named shards.
+func NewLookupResolver(shards map[string]Queryable, lookup func(ctx context.Context, key any) (string, error)) *LookupResolver {
+	names := make([]string, 0, len(shards))
+	for name := range shards {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return &LookupResolver{names: names, shards: shards, lookup: lookup}
+}
+
+// Resolve returns the shard named by the lookup function for key.
+func (r *LookupResolver) Resolve(ctx context.Context, key any) (Queryable, error) {
+	name, err := r.lookup(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	shard, ok := r.shards[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown shard %q for shard key %v", name, key)
+	}
+	return shard, nil
+}
+
+// Shards returns every shard, ordered by name.
+func (r *LookupResolver) Shards() []Queryable {
+	shards := make([]Queryable, len(r.names))
+	for i, name := range r.names {
+		shards[i] = r.shards[name]
+	}
+	return shards
+}
+
+// errRow returns a *sql.Row whose Scan returns err. A *sql.Row can only be created by running a query, so it runs one
+// against a database whose connections always fail with err.
+func errRow(ctx context.Context, err error) *sql.Row {
+	db := sql.OpenDB(errConnector{err: err})
+	defer db.Close()
+	return db.QueryRowContext(ctx, "")
+}
+
+type errConnector struct {
+	err error
+}
+
+func (c errConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, c.err
+}
+
+func (c errConnector) Driver() driver.Driver {
+	return errDriver{err: c.err}
+}
+
+type errDriver struct {
+	err error
+}
+
+func (d errDriver) Open(string) (driver.Conn, error) {
+	return nil, d.err
+}
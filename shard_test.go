@@ -0,0 +1,159 @@
+package sqx_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stytchauth/sqx"
+	"github.com/stytchauth/sqx/sqxtest"
+)
+
+func TestSharding(t *testing.T) {
+	sqx.RegisterShardedTable("sqx_sharded_widgets")
+	ctx := context.Background()
+
+	t.Run("Routes statements by shard key", func(t *testing.T) {
+		shardA, shardB := sqxtest.New(), sqxtest.New()
+		db := sqx.NewShardedQueryable(sqx.NewRangeResolver(
+			sqx.ShardRange[string]{From: "a", Shard: shardA},
+			sqx.ShardRange[string]{From: "n", Shard: shardB},
+		), nil)
+
+		require.NoError(t, sqx.Write(ctx).WithQueryable(db).
+			Insert("sqx_sharded_widgets").
+			SetMap(map[string]any{"customer_id": "alice"}).
+			Shard("alice").
+			Do())
+		require.NoError(t, sqx.Write(ctx).WithQueryable(db).
+			Update("sqx_sharded_widgets").
+			Set("status", "great").
+			Where(sqx.Eq{"customer_id": "zed"}).
+			Shard("zed").
+			Do())
+		_, err := sqx.Read[Widget](sqx.WithShardKey(ctx, "zed")).WithQueryable(db).
+			Select("*").
+			From("sqx_sharded_widgets").
+			All()
+		require.NoError(t, err)
+
+		assert.Len(t, shardA.Calls(), 1)
+		assert.Len(t, shardB.Calls(), 2)
+	})
+
+	t.Run("Fails without a shard key for sharded tables", func(t *testing.T) {
+		db := sqx.NewShardedQueryable(sqx.NewHashResolver(sqxtest.New()), sqxtest.New())
+
+		_, err := sqx.Read[Widget](ctx).WithQueryable(db).Select("*").From("sqx_sharded_widgets w").All()
+		assert.ErrorIs(t, err, sqx.ErrMissingShardKey)
+		err = sqx.Write(ctx).WithQueryable(db).Insert("sqx_sharded_widgets").SetMap(map[string]any{"id": 1}).Do()
+		assert.ErrorIs(t, err, sqx.ErrMissingShardKey)
+		err = sqx.Write(ctx).WithQueryable(db).Update("sqx_sharded_widgets").Set("id", 1).Do()
+		assert.ErrorIs(t, err, sqx.ErrMissingShardKey)
+		err = sqx.Write(ctx).WithQueryable(db).Delete("sqx_sharded_widgets").Do()
+		assert.ErrorIs(t, err, sqx.ErrMissingShardKey)
+	})
+
+	t.Run("Sends statements without a shard key to the fallback", func(t *testing.T) {
+		shard, fallback := sqxtest.New(), sqxtest.New()
+		db := sqx.NewShardedQueryable(sqx.NewHashResolver(shard), fallback)
+
+		_, err := sqx.Read[Widget](ctx).WithQueryable(db).Select("*").From("widgets").All()
+		require.NoError(t, err)
+		assert.Len(t, shard.Calls(), 0)
+		assert.Len(t, fallback.Calls(), 1)
+
+		db = sqx.NewShardedQueryable(sqx.NewHashResolver(shard), nil)
+		_, err = sqx.Read[Widget](ctx).WithQueryable(db).Select("*").From("widgets").All()
+		assert.ErrorIs(t, err, sqx.ErrMissingShardKey)
+		var id int
+		assert.ErrorIs(t, db.QueryRowContext(ctx, "SELECT 1").Scan(&id), sqx.ErrMissingShardKey)
+	})
+
+	t.Run("Hashes keys consistently", func(t *testing.T) {
+		resolver := sqx.NewHashResolver(sqxtest.New(), sqxtest.New(), sqxtest.New())
+		for _, key := range []any{"alice", "bob", 42} {
+			first, err := resolver.Resolve(ctx, key)
+			require.NoError(t, err)
+			second, err := resolver.Resolve(ctx, key)
+			require.NoError(t, err)
+			assert.Same(t, first, second)
+		}
+	})
+
+	t.Run("Looks up shards by name", func(t *testing.T) {
+		east, west := sqxtest.New(), sqxtest.New()
+		resolver := sqx.NewLookupResolver(map[string]sqx.Queryable{"east": east, "west": west},
+			func(_ context.Context, key any) (string, error) {
+				if key == "unknown" {
+					return "", errors.New("no such customer")
+				}
+				return "west", nil
+			})
+
+		shard, err := resolver.Resolve(ctx, "alice")
+		require.NoError(t, err)
+		assert.Same(t, west, shard)
+		_, err = resolver.Resolve(ctx, "unknown")
+		assert.Error(t, err)
+		assert.Equal(t, []sqx.Queryable{east, west}, resolver.Shards())
+	})
+
+	t.Run("Rejects keys outside every range", func(t *testing.T) {
+		resolver := sqx.NewRangeResolver(sqx.ShardRange[int]{From: 100, Shard: sqxtest.New()})
+		_, err := resolver.Resolve(ctx, 99)
+		assert.Error(t, err)
+		_, err = resolver.Resolve(ctx, "100")
+		assert.Error(t, err)
+	})
+
+	t.Run("AllShards merges results from every shard", func(t *testing.T) {
+		shardA, shardB := sqxtest.New(), sqxtest.New()
+		shardA.ExpectQuery("SELECT status FROM sqx_sharded_widgets").
+			WillReturnRows(sqxtest.RowsFromItems("great", "fine"))
+		shardB.ExpectQuery("SELECT status FROM sqx_sharded_widgets").
+			WillReturnRows(sqxtest.RowsFromItems("bad"))
+		db := sqx.NewShardedQueryable(sqx.NewHashResolver(shardA, shardB), nil)
+
+		statuses, err := sqx.Read[string](ctx).WithQueryable(db).Select("status").From("sqx_sharded_widgets").AllShards()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"great", "fine", "bad"}, statuses)
+		shardA.AssertExpectations(t)
+		shardB.AssertExpectations(t)
+
+		_, err = sqx.Read[string](ctx).WithQueryable(shardA).Select("status").From("sqx_sharded_widgets").AllShards()
+		assert.Error(t, err)
+	})
+
+	t.Run("AllShards loads relations from each row's shard", func(t *testing.T) {
+		sqx.RegisterShardedTable("sqx_sharded_pets")
+		shardA, shardB := sqxtest.New(), sqxtest.New()
+		shardA.ExpectQuery("SELECT * FROM sqx_sharded_users").
+			WillReturnRows(sqxtest.RowsFromItems(relUser{ID: "u1", Name: "alice"}))
+		shardA.ExpectQuery("SELECT * FROM sqx_sharded_pets WHERE user_id IN (?)").
+			WithArgs("u1").
+			WillReturnRows(sqxtest.RowsFromItems(relPet{ID: 1, UserID: sqx.Ptr("u1"), Name: "rex"}))
+		shardB.ExpectQuery("SELECT * FROM sqx_sharded_users").
+			WillReturnRows(sqxtest.RowsFromItems(relUser{ID: "u2", Name: "bob"}))
+		shardB.ExpectQuery("SELECT * FROM sqx_sharded_pets WHERE user_id IN (?)").
+			WithArgs("u2").
+			WillReturnRows(sqxtest.RowsFromItems(relPet{ID: 2, UserID: sqx.Ptr("u2"), Name: "tom"}))
+		db := sqx.NewShardedQueryable(sqx.NewHashResolver(shardA, shardB), nil)
+
+		users, err := sqx.Read[relUser](ctx).
+			WithQueryable(db).
+			Select("*").
+			From("sqx_sharded_users").
+			With(sqx.HasMany[relUser, relPet]("id", "user_id").From("sqx_sharded_pets").As("pets")).
+			AllShards()
+		require.NoError(t, err)
+		require.Len(t, users, 2)
+		assert.Equal(t, []relPet{{ID: 1, UserID: sqx.Ptr("u1"), Name: "rex"}}, users[0].Pets)
+		assert.Equal(t, []relPet{{ID: 2, UserID: sqx.Ptr("u2"), Name: "tom"}}, users[1].Pets)
+		shardA.AssertExpectations(t)
+		shardB.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,124 @@
+package sqx
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	sq "github.com/stytchauth/squirrel"
+)
+
+var (
+	softDeleteMu      sync.RWMutex
+	softDeleteColumns = map[string]string{}
+)
+
+// RegisterSoftDelete marks rows in table as soft-deleted by a timestamp column, such as "deleted_at". Once registered:
+//   - DeleteBuilder on the table runs an UPDATE that sets the column to the current time instead of deleting rows, read
+//     with the clock set by SetClock
+//   - SelectBuilder reading from the table only returns rows where the column IS NULL, unless WithDeleted or
+//     OnlyDeleted is called
+//   - Restore can be used to clear the column again
+//
+// The registration is looked up each time a statement on the table is run, so it also applies to builders created
+// before it. Registering the table again replaces its column, and tables cannot be unregistered. RegisterSoftDelete is
+// safe to call concurrently with running queries.
+func RegisterSoftDelete(table string, column string) {
+	softDeleteMu.Lock()
+	defer softDeleteMu.Unlock()
+	softDeleteColumns[table] = column
+}
+
+// softDeleteRef describes a reference to a table that has been registered with RegisterSoftDelete.
+type softDeleteRef struct {
+	from   string
+	table  string
+	alias  string
+	column string
+}
+
+// lookupSoftDelete returns the softDeleteRef for a table reference like "users", "users u" or "users AS u", if the table
+// has been registered with RegisterSoftDelete.
+func lookupSoftDelete(from string) (softDeleteRef, bool) {
+	table, alias := parseTableRef(from)
+	softDeleteMu.RLock()
+	column, ok := softDeleteColumns[table]
+	softDeleteMu.RUnlock()
+	return softDeleteRef{from: from, table: table, alias: alias, column: column}, ok
+}
+
+// parseTableRef splits a table reference like "users", "users u" or "users AS u" into the table name and the name that
+// its columns should be qualified with.
+func parseTableRef(from string) (table string, alias string) {
+	parts := strings.Fields(from)
+	if len(parts) == 0 {
+		return "", ""
+	}
+	return parts[0], parts[len(parts)-1]
+}
+
+// qualified returns the soft delete column qualified by the table's alias (or name), so that it is unambiguous in
+// queries with joins.
+func (r softDeleteRef) qualified() string {
+	return r.alias + "." + r.column
+}
+
+// assignable returns the soft delete column as it should appear on the left-hand side of a SET clause. Some dialects do
+// not allow qualified columns there, so the column is only qualified when the table is aliased.
+func (r softDeleteRef) assignable() string {
+	if r.alias == r.table {
+		return r.column
+	}
+	return r.qualified()
+}
+
+// softDeleteMode controls how a SelectBuilder filters soft-deleted rows.
+type softDeleteMode int
+
+const (
+	// excludeDeleted only returns rows that have not been soft-deleted. This is the default.
+	excludeDeleted softDeleteMode = iota
+	// includeDeleted returns all rows, whether they have been soft-deleted or not.
+	includeDeleted
+	// onlyDeleted only returns rows that have been soft-deleted.
+	onlyDeleted
+)
+
+// softDeletePredicate returns the WHERE predicate for the given table reference and mode, or nil if the table is not
+// registered for soft delete or the mode does not filter.
+func softDeletePredicate(from string, mode softDeleteMode) Sqlizer {
+	ref, ok := lookupSoftDelete(from)
+	if !ok {
+		return nil
+	}
+	switch mode {
+	case excludeDeleted:
+		return Eq{ref.qualified(): nil}
+	case onlyDeleted:
+		return NotEq{ref.qualified(): nil}
+	default:
+		return nil
+	}
+}
+
+// softDeleteBuilder completes update, which holds the clauses of a DELETE statement recorded as an UPDATE, so that it
+// sets the soft delete column to the current time, see SetClock, for every matching row that has not already been
+// soft-deleted.
+func softDeleteBuilder(update sq.UpdateBuilder, ref softDeleteRef) sq.UpdateBuilder {
+	return update.
+		Set(ref.assignable(), clock()).
+		Where(Eq{ref.qualified(): nil})
+}
+
+// Restore constructs a new UpdateBuilder which clears the soft delete column of the given table, which must have been
+// registered with RegisterSoftDelete. Add a Where clause to select the rows to restore.
+func (rc runCtx) Restore(table string) UpdateBuilder {
+	b := rc.Update(table)
+	ref, ok := lookupSoftDelete(table)
+	if !ok {
+		return b.withError(fmt.Errorf("table %q is not registered for soft delete", table))
+	}
+	return b.
+		Set(ref.assignable(), nil).
+		Where(NotEq{ref.qualified(): nil})
+}
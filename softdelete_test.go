@@ -0,0 +1,107 @@
+package sqx
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSoftDeleteSQL(t *testing.T) {
+	RegisterSoftDelete("sqx_soft_deleted_things", "deleted_at")
+	ctx := context.Background()
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	SetClock(func() time.Time { return now })
+	t.Cleanup(func() { SetClock(nil) })
+
+	t.Run("Select filters out soft-deleted rows by default", func(t *testing.T) {
+		query, args, err := Read[string](ctx).
+			Select("id").
+			From("sqx_soft_deleted_things").
+			Where(Eq{"id": "123"}).
+			finalBuilder().
+			ToSql()
+		require.NoError(t, err)
+		assert.Equal(t, "SELECT id FROM sqx_soft_deleted_things WHERE id = ? AND sqx_soft_deleted_things.deleted_at IS NULL", query)
+		assert.Equal(t, []any{"123"}, args)
+	})
+
+	t.Run("Select qualifies the soft delete column with the table alias", func(t *testing.T) {
+		query, _, err := Read[string](ctx).
+			Select("t.id").
+			From("sqx_soft_deleted_things t").
+			Join("others o ON o.thing_id = t.id").
+			finalBuilder().
+			ToSql()
+		require.NoError(t, err)
+		assert.Equal(t, "SELECT t.id FROM sqx_soft_deleted_things t JOIN others o ON o.thing_id = t.id WHERE t.deleted_at IS NULL", query)
+	})
+
+	t.Run("Select can include or only return soft-deleted rows", func(t *testing.T) {
+		b := Read[string](ctx).Select("id").From("sqx_soft_deleted_things")
+
+		query, _, err := b.WithDeleted().finalBuilder().ToSql()
+		require.NoError(t, err)
+		assert.Equal(t, "SELECT id FROM sqx_soft_deleted_things", query)
+
+		query, _, err = b.OnlyDeleted().finalBuilder().ToSql()
+		require.NoError(t, err)
+		assert.Equal(t, "SELECT id FROM sqx_soft_deleted_things WHERE sqx_soft_deleted_things.deleted_at IS NOT NULL", query)
+	})
+
+	t.Run("Select does not filter tables that are not registered", func(t *testing.T) {
+		query, _, err := Read[string](ctx).Select("id").From("sqx_other_things").finalBuilder().ToSql()
+		require.NoError(t, err)
+		assert.Equal(t, "SELECT id FROM sqx_other_things", query)
+	})
+
+	t.Run("Delete becomes an update", func(t *testing.T) {
+		query, args, err := Write(ctx).Delete("sqx_soft_deleted_things").Where(Eq{"id": "123"}).Limit(1).ToSql()
+		require.NoError(t, err)
+		assert.Equal(t, "UPDATE sqx_soft_deleted_things SET deleted_at = ? WHERE id = ? AND sqx_soft_deleted_things.deleted_at IS NULL LIMIT 1", query)
+		assert.Equal(t, []any{now, "123"}, args)
+	})
+
+	t.Run("Delete keeps every clause of the statement", func(t *testing.T) {
+		query, args, err := Write(ctx).
+			Delete("sqx_soft_deleted_things").
+			Prefix("/* prefix */").
+			Where(Eq{"id": "123"}).
+			Where("created_at < ?", "2024-01-01").
+			OrderBy("created_at").
+			Limit(10).
+			Offset(5).
+			Suffix("/* suffix */").
+			ToSql()
+		require.NoError(t, err)
+		assert.Equal(t, "/* prefix */ UPDATE sqx_soft_deleted_things SET deleted_at = ? WHERE id = ? AND created_at < ? AND sqx_soft_deleted_things.deleted_at IS NULL ORDER BY created_at LIMIT 10 OFFSET 5 /* suffix */", query)
+		assert.Equal(t, []any{now, "123", "2024-01-01"}, args)
+	})
+
+	t.Run("Delete handles aliased tables", func(t *testing.T) {
+		query, _, err := Write(ctx).Delete("sqx_soft_deleted_things t").Where(Eq{"t.id": "123"}).ToSql()
+		require.NoError(t, err)
+		assert.Equal(t, "UPDATE sqx_soft_deleted_things t SET t.deleted_at = ? WHERE t.id = ? AND t.deleted_at IS NULL", query)
+	})
+
+	t.Run("Hard delete removes rows from registered tables", func(t *testing.T) {
+		query, args, err := Write(ctx).Delete("sqx_soft_deleted_things").Where(Eq{"id": "123"}).Hard().ToSql()
+		require.NoError(t, err)
+		assert.Equal(t, "DELETE sqx_soft_deleted_things FROM sqx_soft_deleted_things WHERE id = ?", query)
+		assert.Equal(t, []any{"123"}, args)
+	})
+
+	t.Run("Restore clears the soft delete column", func(t *testing.T) {
+		query, args, err := Write(ctx).Restore("sqx_soft_deleted_things").Where(Eq{"id": "123"}).finalBuilder().ToSql()
+		require.NoError(t, err)
+		assert.Equal(t, "UPDATE sqx_soft_deleted_things SET deleted_at = ? WHERE sqx_soft_deleted_things.deleted_at IS NOT NULL AND id = ?", query)
+		assert.Equal(t, []any{nil, "123"}, args)
+	})
+
+	t.Run("Restore returns an error for tables that are not registered", func(t *testing.T) {
+		err := Write(ctx).Restore("sqx_other_things").Do()
+		assert.EqualError(t, err, `table "sqx_other_things" is not registered for soft delete`)
+	})
+}
@@ -13,16 +13,25 @@ type runCtx struct {
 	logger    Logger
 	queryable Queryable
 	ctx       context.Context
+	retry     RetryPolicy
 }
 
 // WithQueryable configures a Queryable for this ctx instance
 func (rc runCtx) WithQueryable(queryable Queryable) runCtx {
-	return runCtx{queryable: queryable, logger: rc.logger, ctx: rc.ctx}
+	rc.queryable = queryable
+	return rc
 }
 
 // WithLogger configures a Logger for this ctx instance
 func (rc runCtx) WithLogger(logger Logger) runCtx {
-	return runCtx{queryable: rc.queryable, logger: logger, ctx: rc.ctx}
+	rc.logger = logger
+	return rc
+}
+
+// WithRetryPolicy configures a RetryPolicy for this ctx instance
+func (rc runCtx) WithRetryPolicy(policy RetryPolicy) runCtx {
+	rc.retry = policy
+	return rc
 }
 
 // typedRunCtx wraps a generic type + a runCtx, it can be used to create typed Select builders
@@ -32,12 +41,17 @@ type typedRunCtx[T any] struct {
 
 // WithQueryable configures a Queryable for this ctx instance
 func (rc typedRunCtx[T]) WithQueryable(queryable Queryable) typedRunCtx[T] {
-	return typedRunCtx[T]{runCtx{queryable: queryable, logger: rc.logger, ctx: rc.ctx}}
+	return typedRunCtx[T]{rc.runCtx.WithQueryable(queryable)}
 }
 
 // WithLogger configures a Logger for this ctx instance
 func (rc typedRunCtx[T]) WithLogger(logger Logger) typedRunCtx[T] {
-	return typedRunCtx[T]{runCtx{queryable: rc.queryable, logger: logger, ctx: rc.ctx}}
+	return typedRunCtx[T]{rc.runCtx.WithLogger(logger)}
+}
+
+// WithRetryPolicy configures a RetryPolicy for this ctx instance
+func (rc typedRunCtx[T]) WithRetryPolicy(policy RetryPolicy) typedRunCtx[T] {
+	return typedRunCtx[T]{rc.runCtx.WithRetryPolicy(policy)}
 }
 
 // Read is the entrypoint for creating generic Select builders
@@ -52,6 +66,7 @@ func Write(ctx context.Context) runCtx {
 		ctx:       ctx,
 		logger:    defaultLogger,
 		queryable: defaultQueryable,
+		retry:     defaultRetryPolicy,
 	}
 }
 
@@ -61,26 +76,81 @@ func TypedWrite[T any](ctx context.Context) typedRunCtx[T] {
 
 // Select constructs a new SelectBuilder for the given columns for this typedRunCtx.
 func (rc typedRunCtx[T]) Select(columns ...string) SelectBuilder[T] {
-	return SelectBuilder[T]{builder: sq.Select(columns...), queryable: rc.queryable, logger: rc.logger, ctx: rc.ctx}
+	return SelectBuilder[T]{
+		builder:   sq.Select(columns...),
+		queryable: rc.queryable,
+		logger:    rc.logger,
+		ctx:       rc.ctx,
+		retry:     rc.retry,
+	}
+}
+
+// SelectStruct constructs a new SelectBuilder which selects the columns of T's db-tagged fields, omitting any listed in
+// excluded. Unlike Select("*"), the query keeps working when columns are added to the table, and only fetches the
+// columns that are scanned. Nested struct fields with a db tag are selected as described in SelectColumns.
+func (rc typedRunCtx[T]) SelectStruct(excluded ...string) SelectBuilder[T] {
+	return rc.SelectStructAlias("", excluded...)
+}
+
+// SelectStructAlias is like SelectStruct but qualifies the columns with a table alias, e.g. "u.id", for queries with
+// joins.
+func (rc typedRunCtx[T]) SelectStructAlias(alias string, excluded ...string) SelectBuilder[T] {
+	columns, err := structColumns[T](alias, excluded...)
+	b := rc.Select(columns...)
+	if err != nil {
+		return b.withError(err)
+	}
+	return b
 }
 
 // Update constructs a new UpdateBuilder for the given table for this typedRunCtx.
 func (rc runCtx) Update(table string) UpdateBuilder {
-	return UpdateBuilder{builder: sq.Update(table), queryable: rc.queryable, logger: rc.logger, ctx: rc.ctx}
+	b := UpdateBuilder{
+		builder:   sq.Update(table),
+		queryable: rc.queryable,
+		logger:    rc.logger,
+		ctx:       rc.ctx,
+		retry:     rc.retry,
+		table:     table,
+	}
+	return b.withScope(table)
 }
 
 // Insert constructs a new InsertBuilder for the given table for this typedRunCtx.
 func (rc runCtx) Insert(table string) InsertBuilder {
-	return InsertBuilder{builder: sq.Insert(table), queryable: rc.queryable, logger: rc.logger, ctx: rc.ctx}
+	return InsertBuilder{
+		builder:   sq.Insert(table),
+		queryable: rc.queryable,
+		logger:    rc.logger,
+		ctx:       rc.ctx,
+		retry:     rc.retry,
+		table:     table,
+	}
 }
 
 func (rc typedRunCtx[T]) InsertMany(table string) InsertManyBuilder[T] {
-	return InsertManyBuilder[T]{builder: sq.Insert(table), queryable: rc.queryable, logger: rc.logger, ctx: rc.ctx}
+	return InsertManyBuilder[T]{
+		builder:   sq.Insert(table),
+		queryable: rc.queryable,
+		logger:    rc.logger,
+		ctx:       rc.ctx,
+		retry:     rc.retry,
+		table:     table,
+	}
 }
 
 // Delete constructs a new DeleteBuilder for the given table for this typedRunCtx.
 func (rc runCtx) Delete(table string) DeleteBuilder {
-	return DeleteBuilder{builder: sq.Delete(table), queryable: rc.queryable, logger: rc.logger, ctx: rc.ctx}
+	b := DeleteBuilder{
+		builder:    sq.Delete(table),
+		queryable:  rc.queryable,
+		logger:     rc.logger,
+		ctx:        rc.ctx,
+		retry:      rc.retry,
+		from:       table,
+		softDelete: sq.Update(table),
+	}
+	return b.withScope()
 }
 
 // runShim maps a Queryable to the squirrel.BaseRunner interface
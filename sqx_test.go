@@ -360,3 +360,170 @@ func TestUpdate(t *testing.T) {
 		assert.True(t, strings.Contains(err.Error(), "sqx_widgets_test' doesn't exist"))
 	})
 }
+
+func TestOptimisticLock(t *testing.T) {
+	type versionedWidget struct {
+		ID      string `db:"widget_id"`
+		Status  string `db:"status"`
+		Version int    `db:"version,version"`
+	}
+
+	ctx := context.Background()
+	tx := Tx(t)
+	_, err := tx.Exec(`DROP TABLE IF EXISTS sqx_versioned_widgets_test;`)
+	require.NoError(t, err)
+	_, err = tx.Exec(`
+		CREATE TABLE sqx_versioned_widgets_test (
+			widget_id		VARCHAR(128) NOT NULL,
+			status			VARCHAR(128) NOT NULL,
+			version			INT NOT NULL DEFAULT 1
+		)
+	`)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, err := tx.Exec(`DROP TABLE IF EXISTS sqx_versioned_widgets_test;`)
+		require.NoError(t, err)
+	})
+
+	getByID := func(widgetID string) *versionedWidget {
+		w, err := sqx.Read[versionedWidget](ctx).
+			WithQueryable(tx).
+			Select("*").
+			From("sqx_versioned_widgets_test").
+			Where(sqx.Eq{"widget_id": widgetID}).
+			OneStrict()
+		require.NoError(t, err)
+		return w
+	}
+	update := func(widgetID string, version int, status string) error {
+		return sqx.Write(ctx).
+			WithQueryable(tx).
+			Update("sqx_versioned_widgets_test").
+			Where(sqx.Eq{"widget_id": widgetID}).
+			SetMap(sqx.ToSetMap(&versionedWidget{ID: widgetID, Status: status, Version: version}, "widget_id")).
+			WithOptimisticLock(version).
+			Do()
+	}
+
+	w := versionedWidget{ID: uuid.New().String(), Status: "great", Version: 42}
+	require.NoError(t, sqx.Write(ctx).
+		WithQueryable(tx).
+		Insert("sqx_versioned_widgets_test").
+		SetMap(sqx.ToSetMap(&w)).
+		Do())
+
+	t.Run("Insert leaves the version column to the database", func(t *testing.T) {
+		assert.Equal(t, 1, getByID(w.ID).Version)
+	})
+
+	t.Run("InsertMany leaves the version column to the database", func(t *testing.T) {
+		w2 := versionedWidget{ID: uuid.New().String(), Status: "great", Version: 42}
+		require.NoError(t, sqx.TypedWrite[versionedWidget](ctx).
+			WithQueryable(tx).
+			InsertMany("sqx_versioned_widgets_test").
+			FromItems([]versionedWidget{w2}).
+			Do())
+		assert.Equal(t, 1, getByID(w2.ID).Version)
+	})
+
+	t.Run("Increments the version when the expected version matches", func(t *testing.T) {
+		require.NoError(t, update(w.ID, 1, "fine"))
+		wdb := getByID(w.ID)
+		assert.Equal(t, "fine", wdb.Status)
+		assert.Equal(t, 2, wdb.Version)
+	})
+
+	t.Run("Returns ErrStaleObject when the expected version does not match", func(t *testing.T) {
+		err := update(w.ID, 1, "alright")
+		assert.ErrorIs(t, err, sqx.ErrStaleObject)
+		wdb := getByID(w.ID)
+		assert.Equal(t, "fine", wdb.Status)
+		assert.Equal(t, 2, wdb.Version)
+	})
+
+	t.Run("Does not increment the version when there are no changes", func(t *testing.T) {
+		err := sqx.Write(ctx).
+			WithQueryable(tx).
+			Update("sqx_versioned_widgets_test").
+			Where(sqx.Eq{"widget_id": w.ID}).
+			WithOptimisticLock(2).
+			Do()
+		assert.NoError(t, err)
+		assert.Equal(t, 2, getByID(w.ID).Version)
+	})
+}
+
+func TestSoftDelete(t *testing.T) {
+	sqx.RegisterSoftDelete("sqx_soft_widgets_test", "deleted_at")
+
+	ctx := context.Background()
+	tx := Tx(t)
+	_, err := tx.Exec(`DROP TABLE IF EXISTS sqx_soft_widgets_test;`)
+	require.NoError(t, err)
+	_, err = tx.Exec(`
+		CREATE TABLE sqx_soft_widgets_test (
+			widget_id		VARCHAR(128) NOT NULL,
+			status			VARCHAR(128) NOT NULL,
+			deleted_at		DATETIME
+		)
+	`)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, err := tx.Exec(`DROP TABLE IF EXISTS sqx_soft_widgets_test;`)
+		require.NoError(t, err)
+	})
+
+	w1 := newWidget("great")
+	w2 := newWidget("fine")
+	for _, w := range []Widget{w1, w2} {
+		require.NoError(t, sqx.Write(ctx).
+			WithQueryable(tx).
+			Insert("sqx_soft_widgets_test").
+			SetMap(map[string]any{"widget_id": w.ID, "status": w.Status}).
+			Do())
+	}
+	ids := func(b sqx.SelectBuilder[string]) []string {
+		ids, err := b.All()
+		require.NoError(t, err)
+		return ids
+	}
+	selectIDs := sqx.Read[string](ctx).
+		WithQueryable(tx).
+		Select("w.widget_id").
+		From("sqx_soft_widgets_test w")
+
+	require.NoError(t, sqx.Write(ctx).
+		WithQueryable(tx).
+		Delete("sqx_soft_widgets_test").
+		Where(sqx.Eq{"widget_id": w1.ID}).
+		Do())
+
+	t.Run("Soft-deleted rows are filtered out", func(t *testing.T) {
+		assert.ElementsMatch(t, []string{w2.ID}, ids(selectIDs))
+	})
+
+	t.Run("Soft-deleted rows are still present", func(t *testing.T) {
+		assert.ElementsMatch(t, []string{w1.ID, w2.ID}, ids(selectIDs.WithDeleted()))
+		assert.ElementsMatch(t, []string{w1.ID}, ids(selectIDs.OnlyDeleted()))
+	})
+
+	t.Run("Restored rows are no longer filtered out", func(t *testing.T) {
+		require.NoError(t, sqx.Write(ctx).
+			WithQueryable(tx).
+			Restore("sqx_soft_widgets_test").
+			Where(sqx.Eq{"widget_id": w1.ID}).
+			Do())
+		assert.ElementsMatch(t, []string{w1.ID, w2.ID}, ids(selectIDs))
+		assert.Empty(t, ids(selectIDs.OnlyDeleted()))
+	})
+
+	t.Run("Hard-deleted rows are removed", func(t *testing.T) {
+		require.NoError(t, sqx.Write(ctx).
+			WithQueryable(tx).
+			Delete("sqx_soft_widgets_test").
+			Where(sqx.Eq{"widget_id": w2.ID}).
+			Hard().
+			Do())
+		assert.ElementsMatch(t, []string{w1.ID}, ids(selectIDs.WithDeleted()))
+	})
+}
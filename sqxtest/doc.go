@@ -0,0 +1,5 @@
+// Package sqxtest provides helpers for unit testing data layers built with sqx without a database.
+//
+// The Fake type implements sqx.Queryable. It records the SQL and args of every statement it runs, returns canned rows
+// and results, and can assert that an expected sequence of statements was run.
+package sqxtest
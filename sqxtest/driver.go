@@ -0,0 +1,115 @@
+package sqxtest
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+)
+
+// connector opens connections to a Fake. It lets each Fake use its own *sql.DB without registering a named driver.
+type connector struct {
+	fake *Fake
+}
+
+func (c connector) Connect(context.Context) (driver.Conn, error) {
+	return conn{fake: c.fake}, nil
+}
+
+func (c connector) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("sqxtest: connections must be opened with sqxtest.New")
+}
+
+// conn routes every statement to its Fake.
+type conn struct {
+	fake *Fake
+}
+
+func (c conn) Prepare(query string) (driver.Stmt, error) {
+	return stmt{conn: c, query: query}, nil
+}
+
+func (c conn) Close() error {
+	return nil
+}
+
+func (c conn) Begin() (driver.Tx, error) {
+	return tx{}, nil
+}
+
+func (c conn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return tx{}, nil
+}
+
+func (c conn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, err := c.fake.handle(KindExec, query, args)
+	if err != nil {
+		return nil, err
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	if e.result == nil {
+		return Result{}, nil
+	}
+	return e.result, nil
+}
+
+func (c conn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	e, err := c.fake.handle(KindQuery, query, args)
+	if err != nil {
+		return nil, err
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	if e.rows == nil {
+		return &driverRows{rows: NewRows()}, nil
+	}
+	return &driverRows{rows: e.rows}, nil
+}
+
+// stmt is only used if database/sql falls back to prepared statements.
+type stmt struct {
+	conn  conn
+	query string
+}
+
+func (s stmt) Close() error {
+	return nil
+}
+
+func (s stmt) NumInput() int {
+	return -1
+}
+
+func (s stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, namedValues(args))
+}
+
+func (s stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, namedValues(args))
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
+
+type tx struct{}
+
+func (tx) Commit() error {
+	return nil
+}
+
+func (tx) Rollback() error {
+	return nil
+}
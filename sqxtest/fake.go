@@ -0,0 +1,263 @@
+package sqxtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Kind is the kind of statement run against a Fake.
+type Kind string
+
+const (
+	// KindExec is a statement run with ExecContext.
+	KindExec Kind = "exec"
+	// KindQuery is a statement run with QueryContext or QueryRowContext.
+	KindQuery Kind = "query"
+)
+
+// Call is a statement that was run against a Fake. Args hold the driver values of the query args, so pointers are
+// dereferenced and integers are widened to int64.
+type Call struct {
+	Kind  Kind
+	Query string
+	Args  []any
+}
+
+// TestingT is the subset of testing.TB used by sqxtest.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// Fake is an sqx.Queryable that records every statement run against it instead of talking to a database.
+//
+// With no expectations, every exec succeeds with no rows affected and every query returns no rows. Once expectations
+// have been added with ExpectExec or ExpectQuery, statements must match them in order, and any other statement fails.
+type Fake struct {
+	db *sql.DB
+
+	mu           sync.Mutex
+	calls        []Call
+	expectations []*Expectation
+	next         int
+	failures     []string
+}
+
+// New creates a new Fake.
+func New() *Fake {
+	f := &Fake{}
+	f.db = sql.OpenDB(connector{fake: f})
+	return f
+}
+
+// ExecContext runs an exec against the Fake.
+func (f *Fake) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return f.db.ExecContext(ctx, query, args...)
+}
+
+// QueryContext runs a query against the Fake.
+func (f *Fake) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return f.db.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext runs a query against the Fake.
+func (f *Fake) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return f.db.QueryRowContext(ctx, query, args...)
+}
+
+// close closes the Fake's DB once a query has been run against it. Rows which were already returned can still be read,
+// and their connection is closed when they are.
+func (f *Fake) close() {
+	_ = f.db.Close()
+}
+
+// DB returns a *sql.DB backed by the Fake. This is useful for testing code that opens transactions - statements run in
+// a transaction are recorded like any other, and commits and rollbacks always succeed.
+func (f *Fake) DB() *sql.DB {
+	return f.db
+}
+
+// Calls returns every statement that has been run against the Fake, in order.
+func (f *Fake) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Call{}, f.calls...)
+}
+
+// Reset clears all recorded calls and expectations.
+func (f *Fake) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = nil
+	f.expectations = nil
+	f.next = 0
+	f.failures = nil
+}
+
+// ExpectExec adds an expectation that the next statement is an exec of query. Queries are compared after collapsing
+// runs of whitespace.
+func (f *Fake) ExpectExec(query string) *Expectation {
+	return f.expect(KindExec, query)
+}
+
+// ExpectQuery adds an expectation that the next statement is a query of query. Queries are compared after collapsing
+// runs of whitespace.
+func (f *Fake) ExpectQuery(query string) *Expectation {
+	return f.expect(KindQuery, query)
+}
+
+func (f *Fake) expect(kind Kind, query string) *Expectation {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	e := &Expectation{kind: kind, query: query}
+	f.expectations = append(f.expectations, e)
+	return e
+}
+
+// ExpectationsWereMet returns an error if any expectation was not met, or if any unexpected statement was run.
+func (f *Fake) ExpectationsWereMet() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	failures := append([]string{}, f.failures...)
+	for _, e := range f.expectations[f.next:] {
+		failures = append(failures, fmt.Sprintf("expected %s was not run: %s", e.kind, e))
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("sqxtest: %s", strings.Join(failures, "\n"))
+	}
+	return nil
+}
+
+// AssertExpectations reports a test error if any expectation was not met, or if any unexpected statement was run.
+func (f *Fake) AssertExpectations(t TestingT) bool {
+	t.Helper()
+	if err := f.ExpectationsWereMet(); err != nil {
+		t.Errorf("%s", err)
+		return false
+	}
+	return true
+}
+
+// handle records a statement and returns the expectation it matches. If there are no expectations at all, it returns
+// an empty expectation so that the statement succeeds.
+func (f *Fake) handle(kind Kind, query string, args []driver.NamedValue) (*Expectation, error) {
+	values := make([]any, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, Call{Kind: kind, Query: query, Args: values})
+
+	if len(f.expectations) == 0 {
+		return &Expectation{}, nil
+	}
+	if f.next >= len(f.expectations) {
+		return nil, f.fail("unexpected %s %q with args %v: all expectations were already met", kind, query, values)
+	}
+	e := f.expectations[f.next]
+	if err := e.match(kind, query, values); err != nil {
+		return nil, f.fail("statement %d does not match the expectation: %s\n- %s %s\n+ %s %q with args %v",
+			f.next+1, err, e.kind, e, kind, query, values)
+	}
+	f.next++
+	return e, nil
+}
+
+func (f *Fake) fail(format string, args ...any) error {
+	failure := fmt.Sprintf(format, args...)
+	f.failures = append(f.failures, failure)
+	return fmt.Errorf("sqxtest: %s", failure)
+}
+
+// Expectation is a statement that a Fake expects to run, along with what it should return.
+type Expectation struct {
+	kind    Kind
+	query   string
+	args    []any
+	hasArgs bool
+	rows    *Rows
+	result  sql.Result
+	err     error
+}
+
+// WithArgs requires the statement to be run with the given args. Args are compared after being converted to driver
+// values, so sqx.Ptr("a") matches "a". Use AnyArg to match any value.
+func (e *Expectation) WithArgs(args ...any) *Expectation {
+	e.args = args
+	e.hasArgs = true
+	return e
+}
+
+// WillReturnRows makes a query return the given rows.
+func (e *Expectation) WillReturnRows(rows *Rows) *Expectation {
+	e.rows = rows
+	return e
+}
+
+// WillReturnResult makes an exec return the given result.
+func (e *Expectation) WillReturnResult(result sql.Result) *Expectation {
+	e.result = result
+	return e
+}
+
+// WillReturnError makes the statement fail with err.
+func (e *Expectation) WillReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+// String describes the expected statement.
+func (e *Expectation) String() string {
+	if !e.hasArgs {
+		return fmt.Sprintf("%q", e.query)
+	}
+	return fmt.Sprintf("%q with args %v", e.query, e.args)
+}
+
+type anyArg struct{}
+
+// AnyArg returns a value that matches any arg in Expectation.WithArgs.
+func AnyArg() any {
+	return anyArg{}
+}
+
+func (e *Expectation) match(kind Kind, query string, args []any) error {
+	if kind != e.kind {
+		return fmt.Errorf("expected %s, got %s", e.kind, kind)
+	}
+	if normalize(query) != normalize(e.query) {
+		return errors.New("queries differ")
+	}
+	if !e.hasArgs {
+		return nil
+	}
+	if len(args) != len(e.args) {
+		return fmt.Errorf("expected %d args, got %d", len(e.args), len(args))
+	}
+	for i, expected := range e.args {
+		if _, ok := expected.(anyArg); ok {
+			continue
+		}
+		converted, err := driver.DefaultParameterConverter.ConvertValue(expected)
+		if err != nil {
+			return fmt.Errorf("could not convert expected arg %d: %w", i, err)
+		}
+		if !reflect.DeepEqual(converted, args[i]) {
+			return fmt.Errorf("arg %d differs", i)
+		}
+	}
+	return nil
+}
+
+// normalize collapses runs of whitespace in query so that formatting differences are ignored.
+func normalize(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
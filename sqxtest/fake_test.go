@@ -0,0 +1,162 @@
+package sqxtest_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stytchauth/sqx"
+	"github.com/stytchauth/sqx/sqxtest"
+)
+
+type widget struct {
+	ID      string  `db:"widget_id"`
+	Status  string  `db:"status"`
+	Enabled bool    `db:"enabled"`
+	OwnerID *string `db:"owner_id"`
+}
+
+func createWidget(ctx context.Context, tx sqx.Queryable, w *widget) error {
+	return sqx.Write(ctx).
+		WithQueryable(tx).
+		Insert("widgets").
+		SetMap(sqx.ToSetMap(w)).
+		Do()
+}
+
+func getWidgetsByStatus(ctx context.Context, tx sqx.Queryable, status string) ([]widget, error) {
+	return sqx.Read[widget](ctx).
+		WithQueryable(tx).
+		Select("*").
+		From("widgets").
+		Where(sqx.Eq{"status": status}).
+		All()
+}
+
+func TestFake(t *testing.T) {
+	ctx := context.Background()
+	w1 := widget{ID: "widget-1", Status: "great", Enabled: true, OwnerID: sqx.Ptr("owner-1")}
+	w2 := widget{ID: "widget-2", Status: "great"}
+
+	t.Run("Records every statement", func(t *testing.T) {
+		fake := sqxtest.New()
+
+		require.NoError(t, createWidget(ctx, fake, &w1))
+		widgets, err := getWidgetsByStatus(ctx, fake, "great")
+		require.NoError(t, err)
+		assert.Empty(t, widgets)
+
+		assert.Equal(t, []sqxtest.Call{
+			{
+				Kind:  sqxtest.KindExec,
+				Query: "INSERT INTO widgets (enabled,owner_id,status,widget_id) VALUES (?,?,?,?)",
+				Args:  []any{true, "owner-1", "great", "widget-1"},
+			},
+			{
+				Kind:  sqxtest.KindQuery,
+				Query: "SELECT * FROM widgets WHERE status = ?",
+				Args:  []any{"great"},
+			},
+		}, fake.Calls())
+	})
+
+	t.Run("Returns canned rows and results", func(t *testing.T) {
+		fake := sqxtest.New()
+		fake.ExpectQuery("SELECT * FROM widgets WHERE status = ?").
+			WithArgs("great").
+			WillReturnRows(sqxtest.RowsFromItems(w1, w2))
+		fake.ExpectQuery("SELECT COUNT(*) FROM widgets").
+			WillReturnRows(sqxtest.NewRows("COUNT(*)").AddRow(2))
+		fake.ExpectExec("UPDATE widgets SET enabled = ? WHERE widget_id = ?").
+			WithArgs(false, sqxtest.AnyArg()).
+			WillReturnResult(sqxtest.NewResult(0, 1))
+
+		widgets, err := getWidgetsByStatus(ctx, fake, "great")
+		require.NoError(t, err)
+		assert.Equal(t, []widget{w1, w2}, widgets)
+
+		count, err := sqx.Read[int](ctx).WithQueryable(fake).Select("COUNT(*)").From("widgets").OneScalar()
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+
+		res, err := sqx.Write(ctx).
+			WithQueryable(fake).
+			Update("widgets").
+			Set("enabled", false).
+			Where(sqx.Eq{"widget_id": w1.ID}).
+			DoResult()
+		require.NoError(t, err)
+		rowsAffected, err := res.RowsAffected()
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), rowsAffected)
+
+		fake.AssertExpectations(t)
+	})
+
+	t.Run("Returns canned errors", func(t *testing.T) {
+		fake := sqxtest.New()
+		expected := errors.New("something went wrong")
+		fake.ExpectExec("INSERT INTO widgets (enabled,owner_id,status,widget_id) VALUES (?,?,?,?)").
+			WillReturnError(expected)
+
+		assert.ErrorIs(t, createWidget(ctx, fake, &w1), expected)
+		fake.AssertExpectations(t)
+	})
+
+	t.Run("Returns row errors", func(t *testing.T) {
+		fake := sqxtest.New()
+		expected := errors.New("connection reset")
+		fake.ExpectQuery("SELECT * FROM widgets WHERE status = ?").
+			WillReturnRows(sqxtest.RowsFromItems(w1).RowError(expected))
+
+		_, err := getWidgetsByStatus(ctx, fake, "great")
+		assert.ErrorIs(t, err, expected)
+	})
+
+	t.Run("Fails statements that do not match the expectations", func(t *testing.T) {
+		fake := sqxtest.New()
+		fake.ExpectQuery("SELECT * FROM widgets WHERE status = ?").WithArgs("fine")
+
+		_, err := getWidgetsByStatus(ctx, fake, "great")
+		assert.Error(t, err)
+		assert.Error(t, fake.ExpectationsWereMet())
+	})
+
+	t.Run("Fails statements once all expectations were met", func(t *testing.T) {
+		fake := sqxtest.New()
+		fake.ExpectQuery("SELECT * FROM widgets WHERE status = ?")
+
+		_, err := getWidgetsByStatus(ctx, fake, "great")
+		require.NoError(t, err)
+		err = createWidget(ctx, fake, &w1)
+		assert.Error(t, err)
+		assert.Error(t, fake.ExpectationsWereMet())
+	})
+
+	t.Run("Reports expectations that were not run", func(t *testing.T) {
+		fake := sqxtest.New()
+		fake.ExpectQuery("SELECT * FROM widgets WHERE status = ?")
+
+		assert.Error(t, fake.ExpectationsWereMet())
+	})
+
+	t.Run("Returns sql.ErrNoRows from empty result sets", func(t *testing.T) {
+		fake := sqxtest.New()
+		_, err := sqx.Read[widget](ctx).WithQueryable(fake).Select("*").From("widgets").One()
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	})
+
+	t.Run("Records statements run in transactions", func(t *testing.T) {
+		fake := sqxtest.New()
+		tx, err := fake.DB().BeginTx(ctx, nil)
+		require.NoError(t, err)
+		require.NoError(t, createWidget(ctx, tx, &w1))
+		require.NoError(t, tx.Commit())
+
+		assert.Len(t, fake.Calls(), 1)
+	})
+}
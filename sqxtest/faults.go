@@ -0,0 +1,246 @@
+package sqxtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"math/rand"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/stytchauth/sqx"
+)
+
+var (
+	// ErrBadConn is the error drivers return when a connection is broken. database/sql retries it on a new connection
+	// when it happens before a statement is sent, but it reaches the caller if it happens inside a transaction.
+	ErrBadConn = driver.ErrBadConn
+	// ErrDeadlock is the error MySQL returns when a transaction is rolled back to resolve a deadlock.
+	ErrDeadlock error = &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock; try restarting transaction"}
+)
+
+// FaultInjector is an sqx.Queryable that runs statements against another Queryable, but injects failures into the
+// statements that match its faults. It is useful for testing retry and transaction handling without a flaky database.
+//
+// Faults are checked in the order they were added, and the first one that applies to a statement is injected.
+type FaultInjector struct {
+	queryable sqx.Queryable
+
+	mu     sync.Mutex
+	faults []*Fault
+	rand   *rand.Rand
+}
+
+// InjectFaults creates a FaultInjector that runs statements against queryable.
+func InjectFaults(queryable sqx.Queryable) *FaultInjector {
+	return &FaultInjector{queryable: queryable, rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// Unwrap returns the Queryable that statements are run against, see sqx.Wrapper.
+func (f *FaultInjector) Unwrap() sqx.Queryable {
+	return f.queryable
+}
+
+// Seed seeds the random numbers used for probabilistic faults, so that a test injects the same faults on every run.
+func (f *FaultInjector) Seed(seed int64) *FaultInjector {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rand = rand.New(rand.NewSource(seed))
+	return f
+}
+
+// Always adds a fault that applies to every statement.
+func (f *FaultInjector) Always() *Fault {
+	return f.add(nil)
+}
+
+// OnQuery adds a fault that applies to statements whose SQL matches the regular expression pattern. OnQuery panics if
+// pattern does not compile.
+func (f *FaultInjector) OnQuery(pattern string) *Fault {
+	return f.add(regexp.MustCompile(pattern))
+}
+
+func (f *FaultInjector) add(pattern *regexp.Regexp) *Fault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fault := &Fault{mu: &f.mu, pattern: pattern, probability: 1, times: -1}
+	f.faults = append(f.faults, fault)
+	return fault
+}
+
+// ExecContext runs an exec against the underlying Queryable, unless a fault is injected.
+func (f *FaultInjector) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	fault := f.next(query)
+	if fault == nil {
+		return f.queryable.ExecContext(ctx, query, args...)
+	}
+	if err := fault.inject(ctx); err != nil {
+		return nil, err
+	}
+	return f.queryable.ExecContext(ctx, query, args...)
+}
+
+// QueryContext runs a query against the underlying Queryable, unless a fault is injected.
+func (f *FaultInjector) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	fault := f.next(query)
+	if fault == nil {
+		return f.queryable.QueryContext(ctx, query, args...)
+	}
+	if err := fault.inject(ctx); err != nil {
+		return nil, err
+	}
+	if !fault.partial {
+		return f.queryable.QueryContext(ctx, query, args...)
+	}
+	fake := f.partialRows(ctx, fault, query, args)
+	defer fake.close()
+	return fake.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext runs a query against the underlying Queryable, unless a fault is injected.
+func (f *FaultInjector) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	fault := f.next(query)
+	if fault == nil {
+		return f.queryable.QueryRowContext(ctx, query, args...)
+	}
+	if err := fault.inject(ctx); err != nil {
+		// *sql.Row can only be created by a driver, so the error is served by a Fake.
+		fake := New()
+		defer fake.close()
+		fake.ExpectQuery(query).WillReturnError(err)
+		return fake.QueryRowContext(ctx, query, args...)
+	}
+	if !fault.partial {
+		return f.queryable.QueryRowContext(ctx, query, args...)
+	}
+	fake := f.partialRows(ctx, fault, query, args)
+	defer fake.close()
+	return fake.QueryRowContext(ctx, query, args...)
+}
+
+// partialRows runs a query against the underlying Queryable and returns a Fake which serves the first rows of the
+// result followed by the fault's error. The Fake serves a single query, and should be closed once it has been run.
+func (f *FaultInjector) partialRows(ctx context.Context, fault *Fault, query string, args []any) *Fake {
+	fake := New()
+	rows, err := readRows(f.queryable.QueryContext(ctx, query, args...))
+	if err != nil {
+		fake.ExpectQuery(query).WillReturnError(err)
+		return fake
+	}
+	if len(rows.values) > fault.partialRows {
+		rows.values = rows.values[:fault.partialRows]
+	}
+	fake.ExpectQuery(query).WillReturnRows(rows.RowError(fault.err))
+	return fake
+}
+
+// next returns the fault to inject into query, if any.
+func (f *FaultInjector) next(query string) *Fault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, fault := range f.faults {
+		if fault.times == 0 {
+			continue
+		}
+		if fault.pattern != nil && !fault.pattern.MatchString(query) {
+			continue
+		}
+		if fault.probability < 1 && f.rand.Float64() >= fault.probability {
+			continue
+		}
+		if fault.times > 0 {
+			fault.times--
+		}
+		fault.injected++
+		return fault
+	}
+	return nil
+}
+
+// Fault is a failure that a FaultInjector injects into matching statements. By default a fault is injected into every
+// matching statement; use WithProbability and Times to inject it less often.
+type Fault struct {
+	mu          *sync.Mutex
+	pattern     *regexp.Regexp
+	probability float64
+	times       int
+	injected    int
+
+	delay       time.Duration
+	err         error
+	cancel      bool
+	partial     bool
+	partialRows int
+}
+
+// WithProbability injects the fault into each matching statement with probability p, between 0 and 1.
+func (f *Fault) WithProbability(p float64) *Fault {
+	f.probability = p
+	return f
+}
+
+// Times injects the fault into at most n statements.
+func (f *Fault) Times(n int) *Fault {
+	f.times = n
+	return f
+}
+
+// WillDelay makes statements wait for d before they run. If the statement's context is done first, the statement
+// fails with the context's error. Delays can be combined with any other fault.
+func (f *Fault) WillDelay(d time.Duration) *Fault {
+	f.delay = d
+	return f
+}
+
+// WillReturnError makes statements fail with err instead of running, e.g. ErrBadConn or ErrDeadlock.
+func (f *Fault) WillReturnError(err error) *Fault {
+	f.err = err
+	return f
+}
+
+// WillCancel makes statements fail with context.Canceled instead of running, as if their context had been canceled.
+func (f *Fault) WillCancel() *Fault {
+	f.cancel = true
+	return f
+}
+
+// WillReturnPartialRows makes queries return at most n rows of their real result, followed by err, as if the
+// connection failed mid-stream. Execs are not affected.
+func (f *Fault) WillReturnPartialRows(n int, err error) *Fault {
+	f.partial = true
+	f.partialRows = n
+	f.err = err
+	return f
+}
+
+// Injected returns the number of statements the fault has been injected into.
+func (f *Fault) Injected() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.injected
+}
+
+// inject applies the fault's delay and returns the error the statement should fail with, if any. Partial results
+// are handled by the FaultInjector, since they need the real result.
+func (f *Fault) inject(ctx context.Context) error {
+	if f.delay > 0 {
+		timer := time.NewTimer(f.delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	switch {
+	case f.cancel:
+		return context.Canceled
+	case f.partial:
+		return nil
+	default:
+		return f.err
+	}
+}
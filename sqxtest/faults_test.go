@@ -0,0 +1,114 @@
+package sqxtest_test
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stytchauth/sqx"
+	"github.com/stytchauth/sqx/sqxtest"
+)
+
+func TestFaultInjector(t *testing.T) {
+	ctx := context.Background()
+	w1 := widget{ID: "widget-1", Status: "great"}
+	w2 := widget{ID: "widget-2", Status: "great"}
+
+	t.Run("Injects errors into matching statements", func(t *testing.T) {
+		db := sqxtest.InjectFaults(sqxtest.New())
+		fault := db.OnQuery(`^INSERT INTO widgets`).WillReturnError(sqxtest.ErrDeadlock)
+
+		assert.ErrorIs(t, createWidget(ctx, db, &w1), sqxtest.ErrDeadlock)
+		_, err := getWidgetsByStatus(ctx, db, "great")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, fault.Injected())
+	})
+
+	t.Run("Injects faults a limited number of times", func(t *testing.T) {
+		fake := sqxtest.New()
+		db := sqxtest.InjectFaults(fake)
+		db.Always().Times(2).WillReturnError(sqxtest.ErrBadConn)
+
+		assert.ErrorIs(t, createWidget(ctx, db, &w1), sqxtest.ErrBadConn)
+		assert.ErrorIs(t, createWidget(ctx, db, &w1), sqxtest.ErrBadConn)
+		assert.NoError(t, createWidget(ctx, db, &w1))
+		assert.Len(t, fake.Calls(), 1)
+	})
+
+	t.Run("Injects faults by probability", func(t *testing.T) {
+		db := sqxtest.InjectFaults(sqxtest.New()).Seed(1)
+		fault := db.Always().WithProbability(0.5).WillReturnError(sqxtest.ErrBadConn)
+
+		for i := 0; i < 100; i++ {
+			_ = createWidget(ctx, db, &w1)
+		}
+		assert.Greater(t, fault.Injected(), 25)
+		assert.Less(t, fault.Injected(), 75)
+	})
+
+	t.Run("Injects latency", func(t *testing.T) {
+		db := sqxtest.InjectFaults(sqxtest.New())
+		db.Always().WillDelay(20 * time.Millisecond)
+
+		start := time.Now()
+		require.NoError(t, createWidget(ctx, db, &w1))
+		assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(ctx, time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, createWidget(ctx, db, &w1), context.DeadlineExceeded)
+	})
+
+	t.Run("Injects cancellations", func(t *testing.T) {
+		db := sqxtest.InjectFaults(sqxtest.New())
+		db.OnQuery(`^SELECT`).WillCancel()
+
+		_, err := getWidgetsByStatus(ctx, db, "great")
+		assert.ErrorIs(t, err, context.Canceled)
+		_, err = sqx.Read[widget](ctx).WithQueryable(db).Select("*").From("widgets").One()
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("Injects partial results", func(t *testing.T) {
+		fake := sqxtest.New()
+		fake.ExpectQuery("SELECT * FROM widgets WHERE status = ?").WillReturnRows(sqxtest.RowsFromItems(w1, w2))
+		db := sqxtest.InjectFaults(fake)
+		db.Always().WillReturnPartialRows(1, sqxtest.ErrBadConn)
+
+		_, err := getWidgetsByStatus(ctx, db, "great")
+		assert.ErrorIs(t, err, sqxtest.ErrBadConn)
+		fake.AssertExpectations(t)
+	})
+
+	t.Run("Does not leak connections when injecting partial results", func(t *testing.T) {
+		fake := sqxtest.New()
+		db := sqxtest.InjectFaults(fake)
+		db.Always().WillReturnPartialRows(1, sqxtest.ErrBadConn)
+		before := runtime.NumGoroutine()
+
+		for i := 0; i < 50; i++ {
+			fake.ExpectQuery("SELECT 1").WillReturnRows(sqxtest.NewRows("1").AddRow(1).AddRow(2))
+			fake.ExpectQuery("SELECT 1").WillReturnRows(sqxtest.NewRows("1").AddRow(1))
+			rows, err := db.QueryContext(ctx, "SELECT 1")
+			require.NoError(t, err)
+			n := 0
+			for rows.Next() {
+				n++
+			}
+			assert.Equal(t, 1, n, "the first row should be served after the fault's DB is closed")
+			assert.ErrorIs(t, rows.Err(), sqxtest.ErrBadConn)
+			assert.NoError(t, rows.Close())
+
+			var one int
+			assert.NoError(t, db.QueryRowContext(ctx, "SELECT 1").Scan(&one))
+			assert.Equal(t, 1, one)
+		}
+		assert.Eventually(t, func() bool {
+			return runtime.NumGoroutine() < before+10
+		}, time.Second, 10*time.Millisecond, "every fault should close the DB that serves its rows")
+	})
+}
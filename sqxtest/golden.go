@@ -0,0 +1,111 @@
+package sqxtest
+
+import (
+	"database/sql/driver"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stytchauth/sqx"
+)
+
+// UpdateFlag is the name of the flag which makes AssertGolden and Recorded write their files instead of comparing
+// against them. It is namespaced so that it does not clash with test packages that define their own -update flag. If a
+// test package does define a boolean -update flag, it is honoured as well.
+const UpdateFlag = "sqxtest.update"
+
+var update = flag.Bool(UpdateFlag, false, "write sqxtest golden files and fixtures instead of comparing against them")
+
+// updating reports whether golden files and fixtures should be written, see UpdateFlag.
+func updating() bool {
+	if *update {
+		return true
+	}
+	if f := flag.Lookup("update"); f != nil {
+		if getter, ok := f.Value.(flag.Getter); ok {
+			on, _ := getter.Get().(bool)
+			return on
+		}
+	}
+	return false
+}
+
+// GoldenDir is the directory, relative to the package under test, that AssertGolden reads golden files from.
+var GoldenDir = "testdata"
+
+// AssertGolden compares the SQL and args generated by builder against the golden file for the current test, which is
+// stored at testdata/<test name>.golden. Run the tests with the -sqxtest.update flag to write the golden files instead,
+// e.g.
+//
+//	go test ./... -run TestMyQueries -sqxtest.update
+//
+// Committing the golden files lets reviewers see exactly how the generated SQL changes in diffs.
+func AssertGolden(t testing.TB, builder sqx.Sqlizer) bool {
+	t.Helper()
+
+	actual, err := renderGolden(builder)
+	if err != nil {
+		t.Errorf("sqxtest: could not render SQL: %s", err)
+		return false
+	}
+
+	path := filepath.Join(GoldenDir, goldenFileName(t.Name()))
+	if updating() {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("sqxtest: could not create golden file directory: %s", err)
+		}
+		if err := os.WriteFile(path, []byte(actual), 0o644); err != nil {
+			t.Fatalf("sqxtest: could not write golden file: %s", err)
+		}
+		return true
+	}
+
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Errorf("sqxtest: could not read golden file - run the tests with -sqxtest.update to create it: %s", err)
+		return false
+	}
+	if string(expected) != actual {
+		t.Errorf("sqxtest: SQL does not match golden file %s - run the tests with -sqxtest.update to update it\n"+
+			"expected:\n%s\nactual:\n%s", path, expected, actual)
+		return false
+	}
+	return true
+}
+
+// renderGolden renders the SQL and args of builder in the golden file format. Args are converted to driver values so
+// that pointers are written as the values they point to.
+func renderGolden(builder sqx.Sqlizer) (string, error) {
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("-- sql --\n")
+	sb.WriteString(query)
+	sb.WriteString("\n-- args --\n")
+	for _, arg := range args {
+		value, err := driver.DefaultParameterConverter.ConvertValue(arg)
+		if err != nil {
+			value = arg
+		}
+		sb.WriteString(fmt.Sprintf("%#v\n", value))
+	}
+	return sb.String(), nil
+}
+
+// goldenFileName converts a test name into a file name, mapping subtests to nested directories.
+func goldenFileName(testName string) string {
+	name := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\\', ':', '*', '?', '"', '<', '>', '|':
+			return '_'
+		}
+		return r
+	}, testName)
+	return name + ".golden"
+}
@@ -0,0 +1,115 @@
+package sqxtest_test
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stytchauth/sqx"
+	"github.com/stytchauth/sqx/sqxtest"
+)
+
+func TestAssertGolden(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Select", func(t *testing.T) {
+		sqxtest.AssertGolden(t, sqx.Read[widget](ctx).
+			Select("*").
+			From("widgets").
+			Where(sqx.Eq{"status": "great", "owner_id": sqx.Ptr("owner-1")}).
+			OrderBy("widget_id").
+			Limit(10))
+	})
+
+	t.Run("Insert", func(t *testing.T) {
+		sqxtest.AssertGolden(t, sqx.Write(ctx).
+			Insert("widgets").
+			SetMap(sqx.ToSetMap(&widget{ID: "widget-1", Status: "great", Enabled: true})))
+	})
+
+	t.Run("InsertMany", func(t *testing.T) {
+		sqxtest.AssertGolden(t, sqx.TypedWrite[widget](ctx).
+			InsertMany("widgets").
+			FromItems([]widget{{ID: "widget-1", Status: "great"}, {ID: "widget-2", Status: "fine"}}))
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		sqxtest.AssertGolden(t, sqx.Write(ctx).
+			Update("widgets").
+			Set("enabled", false).
+			Where(sqx.Eq{"widget_id": "widget-1"}))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		sqxtest.AssertGolden(t, sqx.Write(ctx).
+			Delete("widgets").
+			Where(sqx.Eq{"widget_id": "widget-1"}))
+	})
+
+	t.Run("Reports mismatches", func(t *testing.T) {
+		if flag.Lookup(sqxtest.UpdateFlag).Value.String() == "true" {
+			t.Skip("golden files are being updated")
+		}
+		dir := t.TempDir()
+		defer func(goldenDir string) { sqxtest.GoldenDir = goldenDir }(sqxtest.GoldenDir)
+		sqxtest.GoldenDir = dir
+		golden := "-- sql --\nSELECT * FROM widgets\n-- args --\n"
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "TestAssertGolden"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "TestAssertGolden", "Reports_mismatches.golden"), []byte(golden), 0o644))
+
+		mock := &recordingT{TB: t}
+		ok := sqxtest.AssertGolden(mock, sqx.Read[widget](ctx).Select("*").From("gadgets"))
+		assert.False(t, ok)
+		assert.Len(t, mock.errors, 1)
+	})
+
+	t.Run("Writes golden files when updating", func(t *testing.T) {
+		setFlag(t, sqxtest.UpdateFlag, "true")
+		dir := t.TempDir()
+		defer func(goldenDir string) { sqxtest.GoldenDir = goldenDir }(sqxtest.GoldenDir)
+		sqxtest.GoldenDir = dir
+
+		assert.True(t, sqxtest.AssertGolden(t, sqx.Read[widget](ctx).Select("*").From("gadgets")))
+		golden, err := os.ReadFile(filepath.Join(dir, "TestAssertGolden", "Writes_golden_files_when_updating.golden"))
+		require.NoError(t, err)
+		assert.Equal(t, "-- sql --\nSELECT * FROM gadgets\n-- args --\n", string(golden))
+	})
+
+	t.Run("Does not register a bare update flag", func(t *testing.T) {
+		assert.NotNil(t, flag.Lookup(sqxtest.UpdateFlag))
+		assert.Nil(t, flag.Lookup("update"), "test packages must be free to define their own -update flag")
+	})
+
+	t.Run("Reports builder errors", func(t *testing.T) {
+		mock := &recordingT{TB: t}
+		ok := sqxtest.AssertGolden(mock, sqx.Write(ctx).Insert("widgets").SetMap(nil, errors.New("invalid widget")))
+		assert.False(t, ok)
+		assert.Len(t, mock.errors, 1)
+	})
+}
+
+// recordingT records test errors instead of failing the test.
+type recordingT struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingT) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, format)
+}
+
+// setFlag sets the flag called name to value for the duration of the test.
+func setFlag(t *testing.T, name, value string) {
+	f := flag.Lookup(name)
+	previous := f.Value.String()
+	require.NoError(t, flag.Set(name, value))
+	t.Cleanup(func() {
+		require.NoError(t, flag.Set(name, previous))
+	})
+}
@@ -0,0 +1,352 @@
+package sqxtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stytchauth/sqx"
+)
+
+// Recorder is an sqx.Queryable that runs every statement against another Queryable - usually a real database - and
+// records the SQL, args, columns, rows and results so that they can be saved to a fixture file and played back later
+// with Replay.
+type Recorder struct {
+	queryable sqx.Queryable
+	// fake serves recorded rows back to the caller, since *sql.Rows and *sql.Row can only be created by a driver.
+	fake *Fake
+
+	mu           sync.Mutex
+	interactions []interaction
+}
+
+// Record creates a Recorder that runs statements against queryable.
+func Record(queryable sqx.Queryable) *Recorder {
+	return &Recorder{queryable: queryable, fake: New()}
+}
+
+// Unwrap returns the Queryable that statements are run against, see sqx.Wrapper.
+func (r *Recorder) Unwrap() sqx.Queryable {
+	return r.queryable
+}
+
+// ExecContext runs an exec against the underlying Queryable and records it.
+func (r *Recorder) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	values, err := driverValues(args)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res, err := r.queryable.ExecContext(ctx, query, args...)
+	i := interaction{Kind: KindExec, Query: query, Args: values}
+	if err != nil {
+		i.Error = err.Error()
+	} else {
+		// Not every driver supports both, so errors are recorded as zero values.
+		lastInsertID, _ := res.LastInsertId()
+		rowsAffected, _ := res.RowsAffected()
+		i.Result = &Result{LastInsertID: lastInsertID, Affected: rowsAffected}
+	}
+	r.interactions = append(r.interactions, i)
+	return res, err
+}
+
+// QueryContext runs a query against the underlying Queryable and records it. The rows are read in full before
+// QueryContext returns.
+func (r *Recorder) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.recordQuery(ctx, query, args); err != nil {
+		return nil, err
+	}
+	return r.fake.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext runs a query against the underlying Queryable and records it.
+func (r *Recorder) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_ = r.recordQuery(ctx, query, args)
+	return r.fake.QueryRowContext(ctx, query, args...)
+}
+
+// recordQuery runs a query against the underlying Queryable, records it, and sets up the fake to return the same rows
+// or error. r.mu must be held.
+func (r *Recorder) recordQuery(ctx context.Context, query string, args []any) error {
+	r.fake.Reset()
+	values, err := driverValues(args)
+	if err != nil {
+		r.fake.ExpectQuery(query).WillReturnError(err)
+		return err
+	}
+	i := interaction{Kind: KindQuery, Query: query, Args: values}
+	rows, err := readRows(r.queryable.QueryContext(ctx, query, args...))
+	if err != nil {
+		i.Error = err.Error()
+		r.fake.ExpectQuery(query).WillReturnError(err)
+	} else {
+		i.Columns = rows.columns
+		for _, row := range rows.values {
+			i.Rows = append(i.Rows, toFixtureValues(row))
+		}
+		r.fake.ExpectQuery(query).WillReturnRows(rows)
+	}
+	r.interactions = append(r.interactions, i)
+	return err
+}
+
+// Save writes the recorded statements to a JSON fixture file at path, creating its directory if needed.
+func (r *Recorder) Save(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, err := json.MarshalIndent(fixture{Interactions: r.interactions}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Replay creates a Fake that expects the statements recorded in the fixture file at path, in order, and returns the
+// recorded rows, results and errors. Statements that do not match the recording fail with a diff of the expected and
+// actual SQL and args; use Fake.AssertExpectations to check that every recorded statement was run.
+//
+// Errors are replayed with the same message, but not the same type, as the recorded error.
+func Replay(path string) (*Fake, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("sqxtest: could not parse fixture %s: %w", path, err)
+	}
+
+	fake := New()
+	for _, i := range f.Interactions {
+		args := make([]any, len(i.Args))
+		for j, arg := range i.Args {
+			args[j] = arg.value
+		}
+		e := fake.expect(i.Kind, i.Query).WithArgs(args...)
+		switch {
+		case i.Error != "":
+			e.WillReturnError(errors.New(i.Error))
+		case i.Kind == KindQuery:
+			rows := NewRows(i.Columns...)
+			for _, row := range i.Rows {
+				rows.values = append(rows.values, fromFixtureValues(row))
+			}
+			e.WillReturnRows(rows)
+		case i.Result != nil:
+			e.WillReturnResult(*i.Result)
+		}
+	}
+	return fake, nil
+}
+
+// Recorded returns a Queryable for the current test that is backed by the fixture file at testdata/<test name>.json.
+// When the tests are run with the -sqxtest.update flag, statements are run against the Queryable returned by live and
+// recorded to the fixture file when the test ends. Otherwise, they are replayed from the fixture file without a
+// database, and the test fails if they do not match the recording.
+//
+//	func TestUsers(t *testing.T) {
+//		db := sqxtest.Recorded(t, func() sqx.Queryable { return openTestDB(t) })
+//		...
+//	}
+func Recorded(t testing.TB, live func() sqx.Queryable) sqx.Queryable {
+	t.Helper()
+	path := filepath.Join(GoldenDir, strings.TrimSuffix(goldenFileName(t.Name()), ".golden")+".json")
+
+	if updating() {
+		recorder := Record(live())
+		t.Cleanup(func() {
+			if err := recorder.Save(path); err != nil {
+				t.Errorf("sqxtest: could not write fixture: %s", err)
+			}
+		})
+		return recorder
+	}
+
+	fake, err := Replay(path)
+	if err != nil {
+		t.Fatalf("sqxtest: could not read fixture - run the tests with -sqxtest.update to record it: %s", err)
+	}
+	t.Cleanup(func() {
+		fake.AssertExpectations(t)
+	})
+	return fake
+}
+
+// readRows reads the result of a query into a Rows.
+func readRows(rows *sql.Rows, err error) (*Rows, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	result := NewRows(columns...)
+	for rows.Next() {
+		row := make([]any, len(columns))
+		dest := make([]any, len(columns))
+		for i := range row {
+			dest[i] = &row[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		values := make([]driver.Value, len(row))
+		for i, value := range row {
+			values[i] = value
+		}
+		result.values = append(result.values, values)
+	}
+	return result, rows.Err()
+}
+
+// driverValues converts args to driver values, the same way Fake records them.
+func driverValues(args []any) ([]fixtureValue, error) {
+	values := make([]fixtureValue, len(args))
+	for i, arg := range args {
+		value, err := driver.DefaultParameterConverter.ConvertValue(arg)
+		if err != nil {
+			return nil, fmt.Errorf("sqxtest: could not record arg %d: %w", i, err)
+		}
+		values[i] = fixtureValue{value: value}
+	}
+	return values, nil
+}
+
+// fixture is the JSON format of a recording.
+type fixture struct {
+	Interactions []interaction `json:"interactions"`
+}
+
+// interaction is a single recorded statement.
+type interaction struct {
+	Kind    Kind             `json:"kind"`
+	Query   string           `json:"query"`
+	Args    []fixtureValue   `json:"args"`
+	Columns []string         `json:"columns,omitempty"`
+	Rows    [][]fixtureValue `json:"rows,omitempty"`
+	Result  *Result          `json:"result,omitempty"`
+	Error   string           `json:"error,omitempty"`
+}
+
+// fixtureValue is a driver.Value which is encoded in JSON along with its type, since JSON cannot tell an int64 from a
+// float64 or a string from a []byte. Byte slices are stored as text where possible to keep fixtures readable.
+type fixtureValue struct {
+	value driver.Value
+}
+
+type typedValue struct {
+	Type  string `json:"type"`
+	Value any    `json:"value"`
+}
+
+func (v fixtureValue) MarshalJSON() ([]byte, error) {
+	switch value := v.value.(type) {
+	case nil:
+		return []byte("null"), nil
+	case int64:
+		return json.Marshal(typedValue{Type: "int64", Value: value})
+	case float64:
+		return json.Marshal(typedValue{Type: "float64", Value: value})
+	case bool:
+		return json.Marshal(typedValue{Type: "bool", Value: value})
+	case string:
+		return json.Marshal(typedValue{Type: "string", Value: value})
+	case time.Time:
+		return json.Marshal(typedValue{Type: "time", Value: value.Format(time.RFC3339Nano)})
+	case []byte:
+		if utf8.Valid(value) {
+			return json.Marshal(typedValue{Type: "bytes", Value: string(value)})
+		}
+		return json.Marshal(typedValue{Type: "base64", Value: base64.StdEncoding.EncodeToString(value)})
+	default:
+		return nil, fmt.Errorf("sqxtest: cannot record value of type %T", value)
+	}
+}
+
+func (v *fixtureValue) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		v.value = nil
+		return nil
+	}
+	var typed struct {
+		Type  string          `json:"type"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(data, &typed); err != nil {
+		return err
+	}
+	var err error
+	switch typed.Type {
+	case "int64":
+		var value int64
+		err = json.Unmarshal(typed.Value, &value)
+		v.value = value
+	case "float64":
+		var value float64
+		err = json.Unmarshal(typed.Value, &value)
+		v.value = value
+	case "bool":
+		var value bool
+		err = json.Unmarshal(typed.Value, &value)
+		v.value = value
+	case "string":
+		var value string
+		err = json.Unmarshal(typed.Value, &value)
+		v.value = value
+	case "time":
+		var value time.Time
+		err = json.Unmarshal(typed.Value, &value)
+		v.value = value
+	case "bytes":
+		var value string
+		err = json.Unmarshal(typed.Value, &value)
+		v.value = []byte(value)
+	case "base64":
+		var value []byte
+		err = json.Unmarshal(typed.Value, &value)
+		v.value = value
+	default:
+		err = fmt.Errorf("sqxtest: unknown value type %q", typed.Type)
+	}
+	return err
+}
+
+func toFixtureValues(values []driver.Value) []fixtureValue {
+	result := make([]fixtureValue, len(values))
+	for i, value := range values {
+		result[i] = fixtureValue{value: value}
+	}
+	return result
+}
+
+func fromFixtureValues(values []fixtureValue) []driver.Value {
+	result := make([]driver.Value, len(values))
+	for i, value := range values {
+		result[i] = value.value
+	}
+	return result
+}
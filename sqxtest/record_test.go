@@ -0,0 +1,115 @@
+package sqxtest_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stytchauth/sqx"
+	"github.com/stytchauth/sqx/sqxtest"
+)
+
+type event struct {
+	ID        int64     `db:"id"`
+	Name      string    `db:"name"`
+	Payload   []byte    `db:"payload"`
+	Score     float64   `db:"score"`
+	CreatedAt time.Time `db:"created_at"`
+	DeletedAt *string   `db:"deleted_at"`
+}
+
+// liveEvents is a stand-in for a real database.
+func liveEvents(events ...event) *sqxtest.Fake {
+	live := sqxtest.New()
+	live.ExpectExec("INSERT INTO events (name) VALUES (?)").
+		WithArgs("signup").
+		WillReturnResult(sqxtest.NewResult(3, 1))
+	live.ExpectQuery("SELECT * FROM events WHERE name = ?").
+		WithArgs("signup").
+		WillReturnRows(sqxtest.RowsFromItems(events...))
+	live.ExpectQuery("SELECT COUNT(*) FROM events").
+		WillReturnRows(sqxtest.NewRows("COUNT(*)").AddRow(len(events)))
+	return live
+}
+
+type eventResults struct {
+	lastInsertID int64
+	events       []event
+	count        int
+}
+
+func runEvents(ctx context.Context, db sqx.Queryable) (eventResults, error) {
+	var results eventResults
+	res, err := sqx.Write(ctx).WithQueryable(db).Insert("events").SetMap(map[string]any{"name": "signup"}).DoResult()
+	if err != nil {
+		return results, err
+	}
+	if results.lastInsertID, err = res.LastInsertId(); err != nil {
+		return results, err
+	}
+	results.events, err = sqx.Read[event](ctx).
+		WithQueryable(db).
+		Select("*").
+		From("events").
+		Where(sqx.Eq{"name": "signup"}).
+		All()
+	if err != nil {
+		return results, err
+	}
+	results.count, err = sqx.Read[int](ctx).WithQueryable(db).Select("COUNT(*)").From("events").OneScalar()
+	return results, err
+}
+
+func TestRecordReplay(t *testing.T) {
+	ctx := context.Background()
+	events := []event{
+		{ID: 1, Name: "signup", Payload: []byte{0xff, 0x00}, Score: 1.5, CreatedAt: time.Date(2022, 1, 2, 3, 4, 5, 6, time.UTC)},
+		{ID: 2, Name: "signup", Payload: []byte(`{"a":1}`), DeletedAt: sqx.Ptr("yesterday")},
+	}
+	path := filepath.Join(t.TempDir(), "events.json")
+
+	live := liveEvents(events...)
+	recorder := sqxtest.Record(live)
+	recorded, err := runEvents(ctx, recorder)
+	require.NoError(t, err)
+	require.NoError(t, recorder.Save(path))
+	live.AssertExpectations(t)
+	assert.Equal(t, eventResults{lastInsertID: 3, events: events, count: 2}, recorded)
+
+	t.Run("Replays recorded statements", func(t *testing.T) {
+		fake, err := sqxtest.Replay(path)
+		require.NoError(t, err)
+		replayed, err := runEvents(ctx, fake)
+		require.NoError(t, err)
+		assert.Equal(t, recorded, replayed)
+		fake.AssertExpectations(t)
+	})
+
+	t.Run("Fails statements that diverge from the recording", func(t *testing.T) {
+		fake, err := sqxtest.Replay(path)
+		require.NoError(t, err)
+		err = sqx.Write(ctx).WithQueryable(fake).Insert("events").SetMap(map[string]any{"name": "login"}).Do()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `- exec "INSERT INTO events (name) VALUES (?)" with args [signup]`)
+		assert.Contains(t, err.Error(), `+ exec "INSERT INTO events (name) VALUES (?)" with args [login]`)
+		assert.Error(t, fake.ExpectationsWereMet())
+	})
+
+	t.Run("Fails if the fixture does not exist", func(t *testing.T) {
+		_, err := sqxtest.Replay(filepath.Join(t.TempDir(), "missing.json"))
+		assert.Error(t, err)
+	})
+}
+
+func TestRecorded(t *testing.T) {
+	db := sqxtest.Recorded(t, func() sqx.Queryable {
+		return liveEvents(event{ID: 1, Name: "signup", CreatedAt: time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)})
+	})
+	results, err := runEvents(context.Background(), db)
+	require.NoError(t, err)
+	assert.Equal(t, 1, results.count)
+}
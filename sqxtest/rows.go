@@ -0,0 +1,123 @@
+package sqxtest
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/stytchauth/sqx/internal/dbtag"
+)
+
+// Rows is a canned result set returned by a Fake for a query.
+type Rows struct {
+	columns []string
+	values  [][]driver.Value
+	err     error
+}
+
+// NewRows creates an empty result set with the given columns. Add rows to it with AddRow.
+func NewRows(columns ...string) *Rows {
+	return &Rows{columns: columns}
+}
+
+// AddRow adds a row to the result set. Each value is converted to a driver.Value the same way query args are, so
+// pointers are dereferenced and driver.Valuer types are resolved. AddRow panics if the number of values does not match
+// the number of columns, or if a value cannot be converted.
+func (r *Rows) AddRow(values ...any) *Rows {
+	if len(values) != len(r.columns) {
+		panic(fmt.Sprintf("sqxtest: got %d values for %d columns", len(values), len(r.columns)))
+	}
+	row := make([]driver.Value, len(values))
+	for i, value := range values {
+		converted, err := driver.DefaultParameterConverter.ConvertValue(value)
+		if err != nil {
+			panic(fmt.Sprintf("sqxtest: column %q: %s", r.columns[i], err))
+		}
+		row[i] = converted
+	}
+	r.values = append(r.values, row)
+	return r
+}
+
+// RowError makes the result set return err once every row has been read, as if the connection failed mid-stream.
+func (r *Rows) RowError(err error) *Rows {
+	r.err = err
+	return r
+}
+
+// RowsFromItems creates a result set from a slice of items. If T is a struct, there is one column per db-tagged field,
+// in the same order sqx uses when inserting items. Otherwise, there is a single column named "value".
+func RowsFromItems[T any](items ...T) *Rows {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		rows := NewRows("value")
+		for _, item := range items {
+			rows.AddRow(item)
+		}
+		return rows
+	}
+
+	fields := dbtag.Fields(t)
+	columns := make([]string, len(fields))
+	for i, f := range fields {
+		columns[i] = f.Column
+	}
+	rows := NewRows(columns...)
+	for _, item := range items {
+		value := reflect.ValueOf(item)
+		values := make([]any, len(fields))
+		for i, f := range fields {
+			values[i] = value.FieldByIndex(f.Index).Interface()
+		}
+		rows.AddRow(values...)
+	}
+	return rows
+}
+
+// driverRows iterates over a Rows for a single query.
+type driverRows struct {
+	rows *Rows
+	next int
+}
+
+func (r *driverRows) Columns() []string {
+	return r.rows.columns
+}
+
+func (r *driverRows) Close() error {
+	return nil
+}
+
+func (r *driverRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows.values) {
+		if r.rows.err != nil {
+			return r.rows.err
+		}
+		return io.EOF
+	}
+	copy(dest, r.rows.values[r.next])
+	r.next++
+	return nil
+}
+
+// Result is a canned sql.Result returned by a Fake for an exec.
+type Result struct {
+	LastInsertID int64 `json:"last_insert_id"`
+	Affected     int64 `json:"rows_affected"`
+}
+
+// NewResult creates a Result with the given last insert ID and number of rows affected.
+func NewResult(lastInsertID int64, rowsAffected int64) Result {
+	return Result{LastInsertID: lastInsertID, Affected: rowsAffected}
+}
+
+// LastInsertId returns the canned last insert ID.
+func (r Result) LastInsertId() (int64, error) {
+	return r.LastInsertID, nil
+}
+
+// RowsAffected returns the canned number of rows affected.
+func (r Result) RowsAffected() (int64, error) {
+	return r.Affected, nil
+}
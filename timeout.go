@@ -0,0 +1,60 @@
+package sqx
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lann/builder"
+	sq "github.com/stytchauth/squirrel"
+)
+
+// Timeouts are the default timeouts for each kind of statement, used when a builder has no Timeout of its own. A zero
+// timeout means that statements of that kind only stop when their ctx is done.
+type Timeouts struct {
+	Select time.Duration
+	Insert time.Duration
+	Update time.Duration
+	Delete time.Duration
+}
+
+var defaultTimeouts Timeouts
+
+// SetDefaultTimeouts sets the default timeouts for each kind of statement. See Timeouts.
+func SetDefaultTimeouts(timeouts Timeouts) {
+	defaultTimeouts = timeouts
+}
+
+// withTimeout derives a ctx for running a statement with the given timeout. A zero timeout leaves ctx unchanged.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// timeoutOr returns timeout if it is set, and fallback otherwise.
+func timeoutOr(timeout time.Duration, fallback time.Duration) time.Duration {
+	if timeout > 0 {
+		return timeout
+	}
+	return fallback
+}
+
+// maxExecutionTimeHint returns the MySQL optimizer hint that makes the server abort a SELECT after timeout, even if the
+// client has gone away. Other dialects treat it as a comment.
+func maxExecutionTimeHint(timeout time.Duration) string {
+	ms := timeout.Milliseconds()
+	if ms < 1 {
+		ms = 1
+	}
+	return fmt.Sprintf("/*+ MAX_EXECUTION_TIME(%d) */", ms)
+}
+
+// withMaxExecutionTimeHint adds the MAX_EXECUTION_TIME hint to b. MySQL only recognizes hints that directly follow the
+// SELECT keyword, so the hint goes before any other select options such as DISTINCT.
+func withMaxExecutionTimeHint(b sq.SelectBuilder, timeout time.Duration) sq.SelectBuilder {
+	options, _ := builder.Get(b, "Options")
+	existing, _ := options.([]string)
+	return builder.Set(b, "Options", append([]string{maxExecutionTimeHint(timeout)}, existing...)).(sq.SelectBuilder)
+}
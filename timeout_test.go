@@ -0,0 +1,64 @@
+package sqx_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stytchauth/sqx"
+	"github.com/stytchauth/sqx/sqxtest"
+)
+
+func TestTimeout(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Adds a MAX_EXECUTION_TIME hint to selects", func(t *testing.T) {
+		query, _, err := sqx.Read[string](ctx).
+			Select("status").
+			Distinct().
+			From("widgets").
+			Timeout(1500 * time.Millisecond).
+			ToSql()
+		require.NoError(t, err)
+		assert.Equal(t, "SELECT /*+ MAX_EXECUTION_TIME(1500) */ DISTINCT status FROM widgets", query)
+
+		query, _, err = sqx.Read[string](ctx).Select("status").From("widgets").ToSql()
+		require.NoError(t, err)
+		assert.Equal(t, "SELECT status FROM widgets", query)
+	})
+
+	t.Run("Stops statements that run too long", func(t *testing.T) {
+		db := sqxtest.InjectFaults(sqxtest.New())
+		db.Always().WillDelay(time.Second)
+
+		_, err := sqx.Read[string](ctx).WithQueryable(db).Select("status").From("widgets").Timeout(time.Millisecond).All()
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		err = sqx.Write(ctx).WithQueryable(db).Insert("widgets").SetMap(map[string]any{"id": 1}).Timeout(time.Millisecond).Do()
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		err = sqx.Write(ctx).WithQueryable(db).Update("widgets").Set("id", 1).Timeout(time.Millisecond).Do()
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		err = sqx.Write(ctx).WithQueryable(db).Delete("widgets").Timeout(time.Millisecond).Do()
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("Uses the default timeouts", func(t *testing.T) {
+		sqx.SetDefaultTimeouts(sqx.Timeouts{Select: 2 * time.Second, Delete: time.Millisecond})
+		defer sqx.SetDefaultTimeouts(sqx.Timeouts{})
+		db := sqxtest.InjectFaults(sqxtest.New())
+		db.Always().WillDelay(20 * time.Millisecond)
+
+		query, _, err := sqx.Read[string](ctx).Select("status").From("widgets").ToSql()
+		require.NoError(t, err)
+		assert.Equal(t, "SELECT /*+ MAX_EXECUTION_TIME(2000) */ status FROM widgets", query)
+
+		err = sqx.Write(ctx).WithQueryable(db).Delete("widgets").Do()
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		err = sqx.Write(ctx).WithQueryable(db).Delete("widgets").Timeout(time.Second).Do()
+		assert.NoError(t, err)
+		err = sqx.Write(ctx).WithQueryable(db).Update("widgets").Set("id", 1).Do()
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,82 @@
+package sqx
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	clockMu   sync.RWMutex
+	clockFunc = time.Now
+)
+
+// SetClock sets the function used to read the current time for columns tagged with the "autocreate" or "autoupdate"
+// options. This is mostly useful for making timestamps deterministic in tests. Passing nil restores time.Now.
+func SetClock(now func() time.Time) {
+	if now == nil {
+		now = time.Now
+	}
+	clockMu.Lock()
+	defer clockMu.Unlock()
+	clockFunc = now
+}
+
+// clock returns the current time according to the function set with SetClock.
+func clock() time.Time {
+	clockMu.RLock()
+	now := clockFunc
+	clockMu.RUnlock()
+	return now()
+}
+
+// AutoTimestamp is the value ToSetMap uses for columns tagged with the "autocreate" or "autoupdate" options, e.g.
+// `db:"created_at,autocreate"`, which the builders' SetMap methods replace with the current time so that every
+// timestamp written by a single statement is identical. Autocreate columns only get an AutoTimestamp when the field is
+// zero, and autoupdate columns always get AutoTimestamp{OnUpdate: true}. If it is used directly, it renders the current
+// time as a placeholder arg.
+type AutoTimestamp struct {
+	// OnUpdate reports whether the column is also set whenever the row is updated.
+	OnUpdate bool
+}
+
+// ToSql renders the current time as a placeholder arg.
+func (a AutoTimestamp) ToSql() (string, []interface{}, error) {
+	return "?", []interface{}{clock()}, nil
+}
+
+// fillAutoTimestamps returns a copy of clauses where every AutoTimestamp has been replaced with now.
+func fillAutoTimestamps(clauses map[string]any, now time.Time) map[string]any {
+	filled := make(map[string]any, len(clauses))
+	for column, value := range clauses {
+		if _, ok := value.(AutoTimestamp); ok {
+			value = now
+		}
+		filled[column] = value
+	}
+	return filled
+}
+
+// splitAutoTimestamps returns a copy of clauses without any AutoTimestamp values, along with the columns that should be
+// set to the current time whenever the row is updated.
+func splitAutoTimestamps(clauses map[string]any) (map[string]any, []string) {
+	var autoUpdate []string
+	rest := make(map[string]any, len(clauses))
+	for column, value := range clauses {
+		if ts, ok := value.(AutoTimestamp); ok {
+			if ts.OnUpdate {
+				autoUpdate = append(autoUpdate, column)
+			}
+			continue
+		}
+		rest[column] = value
+	}
+	sort.Strings(autoUpdate)
+	return rest, autoUpdate
+}
+
+// isZero reports whether v is nil or the zero value of its type, e.g. an unset time.Time or a nil *time.Time.
+func isZero(v any) bool {
+	return v == nil || reflect.ValueOf(v).IsZero()
+}
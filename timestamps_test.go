@@ -0,0 +1,107 @@
+package sqx
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type timestampedThingy struct {
+	ID        string    `db:"id"`
+	Name      *string   `db:"name"`
+	CreatedAt time.Time `db:"created_at,autocreate"`
+	UpdatedAt time.Time `db:"updated_at,autoupdate"`
+}
+
+func TestAutoTimestamps(t *testing.T) {
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	SetClock(func() time.Time { return now })
+	t.Cleanup(func() { SetClock(nil) })
+	ctx := context.Background()
+
+	t.Run("ToSetMap always includes timestamp columns", func(t *testing.T) {
+		setMap, err := ToSetMap(&timestampedThingy{ID: "123"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{
+			"id":         "123",
+			"created_at": AutoTimestamp{},
+			"updated_at": AutoTimestamp{OnUpdate: true},
+		}, setMap)
+	})
+
+	t.Run("ToSetMap keeps explicitly set created timestamps", func(t *testing.T) {
+		created := now.Add(-time.Hour)
+		setMap, err := ToSetMap(&timestampedThingy{ID: "123", CreatedAt: created})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{
+			"id":         "123",
+			"created_at": created,
+			"updated_at": AutoTimestamp{OnUpdate: true},
+		}, setMap)
+
+		b := Write(ctx).Insert("thingies").SetMap(setMap, err)
+		_, args, err := b.builder.ToSql()
+		require.NoError(t, err)
+		assert.Equal(t, []any{created, "123", now}, args)
+	})
+
+	t.Run("Insert sets created and updated timestamps", func(t *testing.T) {
+		b := Write(ctx).Insert("thingies").SetMap(ToSetMap(&timestampedThingy{ID: "123"}))
+		query, args, err := b.builder.ToSql()
+		require.NoError(t, err)
+		assert.Equal(t, "INSERT INTO thingies (created_at,id,updated_at) VALUES (?,?,?)", query)
+		assert.Equal(t, []any{now, "123", now}, args)
+	})
+
+	t.Run("InsertMany sets created and updated timestamps", func(t *testing.T) {
+		b := TypedWrite[timestampedThingy](ctx).InsertMany("thingies").FromItems([]timestampedThingy{
+			{ID: "123"},
+			{ID: "456", CreatedAt: now.Add(-time.Hour)},
+		})
+		query, args, err := b.builder.ToSql()
+		require.NoError(t, err)
+		assert.Equal(t, "INSERT INTO thingies (id,name,created_at,updated_at) VALUES (?,?,?,?),(?,?,?,?)", query)
+		assert.Equal(t, []any{"123", (*string)(nil), now, now, "456", (*string)(nil), now.Add(-time.Hour), now}, args, "keeps explicitly set created timestamps")
+	})
+
+	t.Run("Update sets the updated timestamp when there are changes", func(t *testing.T) {
+		b := Write(ctx).
+			Update("thingies").
+			Where(Eq{"id": "123"}).
+			SetMap(ToSetMap(&timestampedThingy{Name: Ptr("new name")}, "id"))
+		query, args, err := b.finalBuilder().ToSql()
+		require.NoError(t, err)
+		assert.Equal(t, "UPDATE thingies SET name = ?, updated_at = ? WHERE id = ?", query)
+		assert.Equal(t, []any{Ptr("new name"), now, "123"}, args)
+	})
+
+	t.Run("Update skips the write when there are no other changes", func(t *testing.T) {
+		b := Write(ctx).
+			Update("thingies").
+			Where(Eq{"id": "123"}).
+			SetMap(ToSetMap(&timestampedThingy{}, "id"))
+		assert.False(t, b.hasChanges)
+		res, err := b.DoResult()
+		require.NoError(t, err)
+		assert.Equal(t, EmptyResult{}, res)
+	})
+}
+
+func TestSetClockConcurrently(t *testing.T) {
+	t.Cleanup(func() { SetClock(nil) })
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			SetClock(time.Now)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		_, _ = ToSetMap(&timestampedThingy{ID: "123"})
+		_ = clock()
+	}
+	<-done
+}
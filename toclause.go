@@ -3,7 +3,7 @@ package sqx
 import (
 	"errors"
 
-	scan "github.com/blockloop/scan/v2"
+	"github.com/stytchauth/sqx/internal/dbtag"
 )
 
 var (
@@ -31,14 +31,14 @@ func ToClause(v any, excluded ...string) *Clause {
 	if isNil(v) {
 		return &Clause{contents: Eq{}, err: nil}
 	}
-	cols, err := scan.ColumnsStrict(v, excluded...)
+	cols, err := dbtag.Columns(v, excluded...)
 	if err != nil {
 		return &Clause{contents: nil, err: err}
 	}
 	if len(cols) == 0 {
 		return &Clause{contents: nil, err: ErrNoDBTags}
 	}
-	vals, err := scan.Values(cols, v)
+	vals, err := dbtag.Values(cols, v)
 	if err != nil {
 		return &Clause{contents: nil, err: err}
 	}
@@ -3,27 +3,39 @@ package sqx
 import (
 	"reflect"
 
-	scan "github.com/blockloop/scan/v2"
+	"github.com/stytchauth/sqx/internal/dbtag"
 )
 
 // ToSetMap converts a struct into a map[string]any based on the presence of "db" struct tags
 // Nil values are skipped over automatically
 // Add fields to the "excluded" arg to exclude them from the row
+// Fields tagged with the "version" option (e.g. `db:"version,version"`) are always skipped, since they are managed by
+// UpdateBuilder.WithOptimisticLock
+// Fields tagged with the "autocreate" or "autoupdate" options (e.g. `db:"created_at,autocreate"`) are always included
+// and are set to the current time by InsertBuilder.SetMap and UpdateBuilder.SetMap - see AutoTimestamp and SetClock.
+// Autocreate fields which are already set keep their value, e.g. for backfills.
 func ToSetMap(v any, excluded ...string) (map[string]any, error) {
 	if isNil(v) {
 		return map[string]any{}, nil
 	}
-	cols, err := scan.ColumnsStrict(v, excluded...)
+	excluded = append(dbtag.ColumnsWithOption(v, dbtag.OptVersion), excluded...)
+	cols, err := dbtag.Columns(v, excluded...)
 	if err != nil {
 		return nil, err
 	}
-	vals, err := scan.Values(cols, v)
+	vals, err := dbtag.Values(cols, v)
 	if err != nil {
 		return nil, err
 	}
+	autoCreate := dbtag.ColumnsWithOption(v, dbtag.OptAutoCreate)
+	autoUpdate := dbtag.ColumnsWithOption(v, dbtag.OptAutoUpdate)
 	setMap := make(map[string]any, len(cols))
 	for i := range cols {
-		if !isNil(vals[i]) {
+		if contains(autoUpdate, cols[i]) {
+			setMap[cols[i]] = AutoTimestamp{OnUpdate: true}
+		} else if contains(autoCreate, cols[i]) && isZero(vals[i]) {
+			setMap[cols[i]] = AutoTimestamp{}
+		} else if !isNil(vals[i]) {
 			setMap[cols[i]] = vals[i]
 		}
 	}
@@ -79,6 +79,22 @@ func TestToSetMap(t *testing.T) {
 		assert.Equal(t, setMap, map[string]any{})
 		assert.NoError(t, err)
 	})
+	t.Run("Skips version columns", func(t *testing.T) {
+		type versionedUpdateFilter struct {
+			StrCol  *string `db:"str_col"`
+			Version *int    `db:"version,version"`
+		}
+		expected := map[string]any{
+			"str_col": sqx.Ptr("i am str"),
+		}
+
+		setMap, err := sqx.ToSetMap(&versionedUpdateFilter{
+			StrCol:  sqx.Ptr("i am str"),
+			Version: sqx.Ptr(3),
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, expected, setMap)
+	})
 }
 
 func TestToSetMapAlias(t *testing.T) {
@@ -0,0 +1,72 @@
+package sqx
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// Tuple2 holds the two columns of a row read with Read2.
+type Tuple2[A, B any] struct {
+	A A
+	B B
+}
+
+// Tuple3 holds the three columns of a row read with Read3.
+type Tuple3[A, B, C any] struct {
+	A A
+	B B
+	C C
+}
+
+// Read2 is the entrypoint for Select builders which read two columns per row into a Tuple2, by position rather than by
+// name, e.g. for `SELECT status, COUNT(*) ... GROUP BY status`. The query must return exactly two columns.
+func Read2[A, B any](ctx context.Context) typedRunCtx[Tuple2[A, B]] {
+	return Read[Tuple2[A, B]](ctx)
+}
+
+// Read3 is like Read2, for three columns read into a Tuple3.
+func Read3[A, B, C any](ctx context.Context) typedRunCtx[Tuple3[A, B, C]] {
+	return Read[Tuple3[A, B, C]](ctx)
+}
+
+// AllMap runs a two-column query built with Read2 and returns a map of the first column to the second. If the first
+// column is not unique, later rows overwrite earlier ones.
+//
+//	counts, err := sqx.AllMap(sqx.Read2[string, int](ctx).Select("status", "COUNT(*)").From("users").GroupBy("status"))
+func AllMap[K comparable, V any](b SelectBuilder[Tuple2[K, V]]) (map[K]V, error) {
+	rows, err := b.All()
+	if err != nil {
+		return nil, err
+	}
+	dest := make(map[K]V, len(rows))
+	for _, row := range rows {
+		dest[row.A] = row.B
+	}
+	return dest, nil
+}
+
+// tuple is implemented by the Tuple types, which are scanned by column position instead of db tags.
+type tuple interface {
+	scanPointers() []any
+}
+
+var tupleType = reflect.TypeOf((*tuple)(nil)).Elem()
+
+func (t *Tuple2[A, B]) scanPointers() []any {
+	return []any{&t.A, &t.B}
+}
+
+func (t *Tuple3[A, B, C]) scanPointers() []any {
+	return []any{&t.A, &t.B, &t.C}
+}
+
+// tuplePointers returns the scan destinations of item, a pointer to a tuple, or an error if the number of columns does
+// not match the tuple.
+func tuplePointers(item any, cols []string) ([]any, error) {
+	pointers := item.(tuple).scanPointers()
+	if len(pointers) != len(cols) {
+		return nil, fmt.Errorf("cannot scan %d columns into %T: expected %d", len(cols), item, len(pointers))
+	}
+	return pointers, nil
+}
@@ -0,0 +1,62 @@
+package sqx_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stytchauth/sqx"
+	"github.com/stytchauth/sqx/sqxtest"
+)
+
+func TestTuples(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Scans columns by position", func(t *testing.T) {
+		fake := sqxtest.New()
+		fake.ExpectQuery("SELECT widget_id, status, owner_id FROM widgets").
+			WillReturnRows(sqxtest.NewRows("widget_id", "status", "owner_id").
+				AddRow("w1", "great", "o1").
+				AddRow("w2", "fine", nil))
+
+		rows, err := sqx.Read3[string, string, sql.NullString](ctx).
+			WithQueryable(fake).
+			Select("widget_id", "status", "owner_id").
+			From("widgets").
+			All()
+		require.NoError(t, err)
+		assert.Equal(t, []sqx.Tuple3[string, string, sql.NullString]{
+			{A: "w1", B: "great", C: sql.NullString{String: "o1", Valid: true}},
+			{A: "w2", B: "fine"},
+		}, rows)
+	})
+
+	t.Run("Requires the number of columns to match", func(t *testing.T) {
+		fake := sqxtest.New()
+		fake.ExpectQuery("SELECT * FROM widgets").WillReturnRows(sqxtest.NewRows("widget_id", "status", "owner_id"))
+
+		_, err := sqx.Read2[string, string](ctx).WithQueryable(fake).Select("*").From("widgets").All()
+		assert.ErrorContains(t, err, "cannot scan 3 columns into *sqx.Tuple2[string,string]: expected 2")
+	})
+
+	t.Run("Reads two columns into a map", func(t *testing.T) {
+		tx := Tx(t)
+		setupTestWidgetsTable(t, tx)
+		dbWidget := newDBWidget()
+		for _, status := range []string{"great", "great", "fine"} {
+			widget := newWidget(status)
+			require.NoError(t, dbWidget.Create(ctx, tx, &widget))
+		}
+
+		counts, err := sqx.AllMap(sqx.Read2[string, int](ctx).
+			WithQueryable(tx).
+			Select("status", "COUNT(*)").
+			From("sqx_widgets_test").
+			GroupBy("status"))
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{"great": 2, "fine": 1}, counts)
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"time"
 
 	sq "github.com/stytchauth/squirrel"
 )
@@ -15,8 +16,23 @@ type UpdateBuilder struct {
 	queryable  Queryable
 	ctx        context.Context
 	err        error
+	table      string
 	hasChanges bool
 	logger     Logger
+	lock       *optimisticLock
+	autoUpdate []string
+	timeout    time.Duration
+	retry      RetryPolicy
+	idempotent bool
+}
+
+// DefaultVersionColumn is the column used by UpdateBuilder.WithOptimisticLock
+const DefaultVersionColumn = "version"
+
+// optimisticLock holds the version column and the version the row is expected to have when the update runs.
+type optimisticLock struct {
+	column  string
+	version any
 }
 
 // ============================================
@@ -64,19 +80,38 @@ func (b UpdateBuilder) CrossJoin(join string, rest ...interface{}) UpdateBuilder
 }
 
 // Set adds SET clauses to the query.
+// If the table was registered with RegisterScopedTable, setting a scope column to any value other than the one in the
+// ctx scope fails with ErrScopeViolation.
 func (b UpdateBuilder) Set(column string, value any) UpdateBuilder {
+	if err := checkScopeSet(b.ctx, b.table, column, value); err != nil {
+		return b.withError(err)
+	}
 	return b.
 		withBuilder(b.builder.Set(column, value)).
 		withChanges()
 }
 
 // SetMap is a convenience method which calls Set for each key/value pair in clauses.
+// Scope columns are checked as in Set.
+// Timestamp columns managed by ToSetMap are set to the current time if the update has any other changes, except for
+// "autocreate" columns which are left untouched.
 func (b UpdateBuilder) SetMap(clauses map[string]any, errors ...error) UpdateBuilder {
 	for _, err := range errors {
 		if err != nil {
 			return b.withError(err)
 		}
 	}
+	for column, value := range clauses {
+		if err := checkScopeSet(b.ctx, b.table, column, value); err != nil {
+			return b.withError(err)
+		}
+	}
+	clauses, autoUpdate := splitAutoTimestamps(clauses)
+	for _, column := range autoUpdate {
+		if !contains(b.autoUpdate, column) {
+			b.autoUpdate = append(b.autoUpdate, column)
+		}
+	}
 	if len(clauses) == 0 {
 		return b
 	}
@@ -121,6 +156,46 @@ func (b UpdateBuilder) SuffixExpr(expr Sqlizer) UpdateBuilder {
 // END: squirrel-UpdateBuilder parity section
 // ==========================================
 
+// Timeout limits how long the update may run, overriding the default from SetDefaultTimeouts. The update fails with
+// context.DeadlineExceeded if it runs for longer.
+func (b UpdateBuilder) Timeout(timeout time.Duration) UpdateBuilder {
+	b.timeout = timeout
+	return b
+}
+
+// Idempotent marks the update as safe to run more than once, so that it is retried according to the RetryPolicy. Only
+// mark writes whose effect is the same if they are applied twice, since a write which failed with a broken connection
+// may have been applied anyway.
+func (b UpdateBuilder) Idempotent() UpdateBuilder {
+	b.idempotent = true
+	return b
+}
+
+// Shard sets the shard key that a ShardedQueryable uses to pick the shard to run the update against. See WithShardKey.
+func (b UpdateBuilder) Shard(key any) UpdateBuilder {
+	if b.ctx != nil {
+		b.ctx = WithShardKey(b.ctx, key)
+	}
+	return b
+}
+
+// WithOptimisticLock guards the update with the DefaultVersionColumn. See WithOptimisticLockColumn.
+func (b UpdateBuilder) WithOptimisticLock(currentVersion any) UpdateBuilder {
+	return b.WithOptimisticLockColumn(DefaultVersionColumn, currentVersion)
+}
+
+// WithOptimisticLockColumn enables optimistic concurrency control for the update. The update only applies to rows whose
+// version column still equals currentVersion, and increments the version column whenever changes are written. If no
+// rows are affected, DoResult returns ErrStaleObject.
+//
+// Fields tagged with the "version" option (e.g. `db:"version,version"`) are skipped by ToSetMap, so the version column
+// is only ever written by this mechanism.
+func (b UpdateBuilder) WithOptimisticLockColumn(column string, currentVersion any) UpdateBuilder {
+	b = b.Where(Eq{column: currentVersion})
+	b.lock = &optimisticLock{column: column, version: currentVersion}
+	return b
+}
+
 // Do executes the UpdateBuilder
 func (b UpdateBuilder) Do() error {
 	_, err := b.DoResult()
@@ -133,43 +208,108 @@ func (b UpdateBuilder) DoResult() (sql.Result, error) {
 	if b.err != nil {
 		return nil, b.err
 	}
+	if err := checkShardKey(b.ctx, b.table); err != nil {
+		return nil, err
+	}
 	if !b.hasChanges {
 		log.Println("Skipping write to DB - no updates set")
 		return EmptyResult{}, nil
 	}
+	if collector := CollectorFromContext(b.ctx); collector != nil {
+		return collector.record(b)
+	}
 	if b.queryable == nil {
 		return nil, fmt.Errorf("missing queryable - call SetDefaultQueryable or WithQueryable to set it")
 	}
-	return b.builder.RunWith(runShim{b.queryable}).ExecContext(b.ctx)
+	res, err := withRetries(b.ctx, b.retry, b.idempotent, b.queryable, b.logger, func() (sql.Result, error) {
+		ctx, cancel := withTimeout(b.ctx, timeoutOr(b.timeout, defaultTimeouts.Update))
+		defer cancel()
+		return b.finalBuilder().RunWith(runShim{b.queryable}).ExecContext(ctx)
+	})
+	if err != nil || b.lock == nil {
+		return res, err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rowsAffected == 0 {
+		return nil, ErrStaleObject
+	}
+	return res, nil
+}
+
+// ToSql returns the SQL query and args that DoResult would run, or the first error that occurred while building it.
+// Note that DoResult skips the query entirely if no changes have been set.
+func (b UpdateBuilder) ToSql() (string, []interface{}, error) {
+	if b.err != nil {
+		return "", nil, b.err
+	}
+	return b.finalBuilder().ToSql()
 }
 
 // Debug prints the UpdateBuilder state out to the provided logger
 func (b UpdateBuilder) Debug() UpdateBuilder {
-	debug(b.logger, b.builder)
+	debug(b.logger, b)
 	return b
 }
 
+// finalBuilder returns the underlying squirrel builder with any SET clauses managed by sqx itself applied. These are
+// added last so that they are only present when the caller has set changes of their own.
+func (b UpdateBuilder) finalBuilder() sq.UpdateBuilder {
+	builder := b.builder
+	if !b.hasChanges {
+		return builder
+	}
+	if len(b.autoUpdate) > 0 {
+		now := clock()
+		for _, column := range b.autoUpdate {
+			builder = builder.Set(column, now)
+		}
+	}
+	if b.lock != nil {
+		builder = builder.Set(b.lock.column, sq.Expr(b.lock.column+" + 1"))
+	}
+	return builder
+}
+
 // WithQueryable configures a Queryable for this UpdateBuilder instance
 func (b UpdateBuilder) WithQueryable(queryable Queryable) UpdateBuilder {
-	return UpdateBuilder{builder: b.builder, queryable: queryable, logger: b.logger, ctx: b.ctx, err: b.err, hasChanges: b.hasChanges}
+	b.queryable = queryable
+	return b
 }
 
 // WithLogger configures a Queryable for this UpdateBuilder instance
 func (b UpdateBuilder) WithLogger(logger Logger) UpdateBuilder {
-	return UpdateBuilder{builder: b.builder, queryable: b.queryable, logger: logger, ctx: b.ctx, err: b.err, hasChanges: b.hasChanges}
+	b.logger = logger
+	return b
 }
 
 func (b UpdateBuilder) withError(err error) UpdateBuilder {
 	if b.err != nil {
 		return b
 	}
-	return UpdateBuilder{builder: b.builder, queryable: b.queryable, logger: b.logger, ctx: b.ctx, err: err, hasChanges: b.hasChanges}
+	b.err = err
+	return b
+}
+
+// withScope restricts the UpdateBuilder to the ctx scope if table was registered with RegisterScopedTable.
+func (b UpdateBuilder) withScope(table string) UpdateBuilder {
+	pred, err := scopePredicate(b.ctx, table)
+	if err != nil {
+		return b.withError(err)
+	} else if pred != nil {
+		return b.Where(pred)
+	}
+	return b
 }
 
 func (b UpdateBuilder) withBuilder(builder sq.UpdateBuilder) UpdateBuilder {
-	return UpdateBuilder{builder: builder, queryable: b.queryable, logger: b.logger, ctx: b.ctx, err: b.err, hasChanges: b.hasChanges}
+	b.builder = builder
+	return b
 }
 
 func (b UpdateBuilder) withChanges() UpdateBuilder {
-	return UpdateBuilder{builder: b.builder, queryable: b.queryable, logger: b.logger, ctx: b.ctx, err: b.err, hasChanges: true}
+	b.hasChanges = true
+	return b
 }